@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// previewSize is how much of every file we read to find its first heading.
+const previewSize = 16 * 1024
+
+// fileItem is a Markdown file in the directory browser.
+type fileItem struct {
+	path    string
+	rel     string
+	heading string
+}
+
+func (i fileItem) Title() string { return i.rel }
+
+func (i fileItem) Description() string {
+	if i.heading == "" {
+		return "No heading"
+	}
+	return i.heading
+}
+
+func (i fileItem) FilterValue() string { return i.rel + " " + i.heading }
+
+// filesMsg carries the result of walking the browsed directory.
+type filesMsg struct {
+	items []list.Item
+	err   error
+}
+
+// browser lists all Markdown files under a directory.
+type browser struct {
+	root string
+	list list.Model
+}
+
+func newBrowser(root string) *browser {
+	l := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	l.Title = root
+	l.SetStatusBarItemName("document", "documents")
+	l.DisableQuitKeybindings()
+	return &browser{root: root, list: l}
+}
+
+// isDir reports whether the document argument is a local directory.
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// findFiles walks the directory in the background, so the browser shows up
+// right away even for big trees.
+func (b *browser) findFiles() tea.Msg {
+	items, err := walkMarkdown(b.root)
+	return filesMsg{items: items, err: err}
+}
+
+// walkMarkdown finds all Markdown files under root, skipping everything the
+// .gitignore files along the way tell us to.
+func walkMarkdown(root string) ([]list.Item, error) {
+	ignores := map[string]*ignore.GitIgnore{}
+
+	var items []list.Item
+	err := filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() && e.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if path != root && ignored(ignores, root, path, e.IsDir()) {
+			if e.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e.IsDir() {
+			if gi, err := ignore.CompileIgnoreFile(filepath.Join(path, ".gitignore")); err == nil {
+				ignores[path] = gi
+			}
+			return nil
+		}
+		if !isMarkdown(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		items = append(items, fileItem{path: path, rel: rel, heading: firstHeading(path)})
+		return nil
+	})
+	return items, err
+}
+
+// ignored checks the path against the .gitignore files of all directories
+// above it. The patterns of a .gitignore are relative to its directory.
+func ignored(ignores map[string]*ignore.GitIgnore, root, path string, dir bool) bool {
+	for d := filepath.Dir(path); ; d = filepath.Dir(d) {
+		if gi, ok := ignores[d]; ok {
+			rel, err := filepath.Rel(d, path)
+			if err == nil {
+				rel = filepath.ToSlash(rel)
+				if gi.MatchesPath(rel) || (dir && gi.MatchesPath(rel+"/")) {
+					return true
+				}
+			}
+		}
+		if d == root || d == filepath.Dir(d) {
+			return false
+		}
+	}
+}
+
+// firstHeading returns the first heading of the file, or nothing if it
+// doesn't start with one soon enough.
+func firstHeading(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, previewSize))
+	if err != nil {
+		return ""
+	}
+	meta, body := splitFrontMatter(string(head))
+	if t := meta.title(); t != "" {
+		return t
+	}
+	if h := parseHeadings(body); len(h) > 0 {
+		return strings.TrimSpace(h[0].text)
+	}
+	return ""
+}
+
+// updateBrowser handles messages while the directory browser is shown.
+func (m model) updateBrowser(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case filesMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, m.browser.list.SetItems(msg.items)
+
+	case tea.KeyMsg:
+		m.err = nil
+		if m.browser.list.SettingFilter() {
+			break
+		}
+		switch {
+		// Esc is left to the list, which uses it to clear the filter.
+		case key.Matches(msg, keys.Quit) && msg.Type != tea.KeyEsc:
+			return m, tea.Quit
+		case key.Matches(msg, keys.OpenLink):
+			item, ok := m.browser.list.SelectedItem().(fileItem)
+			if !ok {
+				return m, nil
+			}
+			m.err = m.openFromBrowser(item.path)
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.browser.list, cmd = m.browser.list.Update(msg)
+	return m, cmd
+}
+
+// openFromBrowser opens the file in the pager. All files are opened in the
+// same tab, so the back history still works across them.
+func (m *model) openFromBrowser(path string) error {
+	d, err := loadDocument(path, newSearch(m.regex, m.ignoreCase))
+	if err != nil {
+		return err
+	}
+	m.opened(d)
+	if len(m.tabs) == 0 {
+		m.tabs = append(m.tabs, newTab(d))
+	} else {
+		m.tab().visit(d)
+	}
+	if m.follow != nil {
+		if err := m.follow.add(d); err != nil {
+			return err
+		}
+	}
+	m.browsing = false
+	m.layout()
+	return nil
+}
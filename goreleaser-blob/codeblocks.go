@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+var selectedBlockStyle = lipgloss.NewStyle().Reverse(true)
+
+// codeBlock is a fenced code block, with its text exactly as it is in the
+// Markdown source and the rendered lines it ended up on.
+type codeBlock struct {
+	text       string
+	start, end int
+}
+
+// copiedMsg reports how copying to the clipboard went.
+type copiedMsg struct {
+	lines int
+	err   error
+}
+
+// parseCodeBlocks extracts the text of all fenced code blocks in document
+// order.
+func parseCodeBlocks(source string) []codeBlock {
+	src := []byte(source)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	var blocks []codeBlock
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cb, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			var b strings.Builder
+			for i := 0; i < cb.Lines().Len(); i++ {
+				seg := cb.Lines().At(i)
+				b.Write(seg.Value(src))
+			}
+			if strings.TrimSpace(b.String()) != "" {
+				blocks = append(blocks, codeBlock{text: b.String()})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return blocks
+}
+
+// locateCodeBlocks finds the rendered lines of every code block. Glamour
+// indents and highlights the code, and wraps long lines even in the middle
+// of a word, so we match the code without styling and allow any whitespace
+// between its characters. The code has to fill the lines it is on, apart
+// from the margin, so a short block doesn't match a word in the prose.
+func locateCodeBlocks(blocks []codeBlock, lines []string) []codeBlock {
+	p := newPlainText(lines)
+	var found []codeBlock
+	from := 0
+	for _, b := range blocks {
+		var expr strings.Builder
+		for _, r := range b.text {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			if expr.Len() > 0 {
+				expr.WriteString(`\s*`)
+			}
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		re, err := regexp.Compile(`(?m)^[ \t]*(` + expr.String() + `)[ \t]*$`)
+		if err != nil {
+			continue
+		}
+		loc := re.FindStringSubmatchIndex(p.text[from:])
+		if loc == nil {
+			continue
+		}
+		b.start = p.position(from + loc[2]).line
+		b.end = p.position(from + loc[3] - 1).line
+		found = append(found, b)
+		from += loc[1]
+	}
+	return found
+}
+
+// highlightBlock returns a copy of lines with the selected code block
+// highlighted.
+func (d *document) highlightBlock(lines []string) []string {
+	if d.selectedBlock < 0 || d.selectedBlock >= len(d.codeBlocks) {
+		return lines
+	}
+	out := make([]string, len(lines))
+	copy(out, lines)
+	b := d.codeBlocks[d.selectedBlock]
+	for i := b.start; i <= b.end && i < len(out); i++ {
+		plain := ansi.Strip(out[i])
+		start := ansi.StringWidth(plain) - ansi.StringWidth(strings.TrimLeft(plain, " "))
+		end := ansi.StringWidth(strings.TrimRight(plain, " "))
+		if end > start {
+			out[i] = lipgloss.StyleRanges(out[i], lipgloss.NewRange(start, end, selectedBlockStyle))
+		}
+	}
+	return out
+}
+
+// selectBlock moves the selection to the next code block on screen, starting
+// over at the first one after the last.
+func (d *document) selectBlock() error {
+	top, bottom := d.viewport.YOffset, d.viewport.YOffset+d.viewport.Height
+	var visible []int
+	for i, b := range d.codeBlocks {
+		if b.end >= top && b.start < bottom {
+			visible = append(visible, i)
+		}
+	}
+	if len(visible) == 0 {
+		return fmt.Errorf("no code blocks on screen")
+	}
+	next := visible[0]
+	for j, i := range visible {
+		if i == d.selectedBlock && j+1 < len(visible) {
+			next = visible[j+1]
+		}
+	}
+	d.selectedBlock = next
+	d.updateContent()
+	return nil
+}
+
+// yankBlock copies the selected code block to the clipboard.
+func (d *document) yankBlock() (tea.Cmd, error) {
+	if d.selectedBlock < 0 || d.selectedBlock >= len(d.codeBlocks) {
+		return nil, fmt.Errorf("no code block selected, select one with c")
+	}
+	return copyToClipboard(d.codeBlocks[d.selectedBlock].text), nil
+}
+
+// copyToClipboard sets the clipboard with an OSC 52 escape sequence, which
+// the terminal handles even when we are running over SSH. Not all terminals
+// support it and there's no way to find out, so if there is a clipboard tool
+// for the local display, we use it as well. The sequence goes through the
+// output of the program, so it can't end up in the middle of a frame.
+func copyToClipboard(s string) tea.Cmd {
+	return func() tea.Msg {
+		seq := ansi.SetSystemClipboard(s)
+		if os.Getenv("TMUX") != "" {
+			seq = ansi.TmuxPassthrough(seq)
+		}
+		if _, err := stdout.WriteString(seq); err != nil {
+			return copiedMsg{err: err}
+		}
+
+		lines := strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1
+		var tool *exec.Cmd
+		switch {
+		case os.Getenv("WAYLAND_DISPLAY") != "":
+			if path, err := exec.LookPath("wl-copy"); err == nil {
+				tool = exec.Command(path)
+			}
+		case os.Getenv("DISPLAY") != "":
+			if path, err := exec.LookPath("xclip"); err == nil {
+				tool = exec.Command(path, "-selection", "clipboard")
+			}
+		}
+		if tool == nil {
+			return copiedMsg{lines: lines}
+		}
+		tool.Stdin = strings.NewReader(s)
+		if err := tool.Run(); err != nil {
+			return copiedMsg{lines: lines, err: fmt.Errorf("%s: %w", tool.Path, err)}
+		}
+		return copiedMsg{lines: lines}
+	}
+}
+
+// stdout is the output of the program. Writes hold a lock, so that the
+// escape sequences we write ourselves don't get mixed up with the frames the
+// renderer writes at the same time. It's still a terminal file, so the
+// program can find out its size.
+var stdout = &lockedFile{File: os.Stdout}
+
+type lockedFile struct {
+	*os.File
+	mu sync.Mutex
+}
+
+func (f *lockedFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.Write(b)
+}
+
+func (f *lockedFile) WriteString(s string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.WriteString(s)
+}
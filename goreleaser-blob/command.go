@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var lineNumberStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+
+// command is the vim-like ":" prompt. It understands
+//
+//	:120           go to line 120
+//	:50%           go to the middle of the document
+//	:h Install     go to the first heading starting with, or else containing, "Install"
+//	:marks         list the bookmarks
+//	:delmarks a b  delete bookmarks, :delmarks! deletes all of them
+//
+// Lines are the lines of the file, as shown in the line number gutter.
+type command struct {
+	input     textinput.Model
+	prompting bool
+}
+
+func newCommand() command {
+	ti := textinput.New()
+	ti.Prompt = ":"
+	return command{input: ti}
+}
+
+// updateCommand handles key presses while the command prompt is open.
+func (m model) updateCommand(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.SearchConfirm):
+		m.command.prompting = false
+		m.command.input.Blur()
+		if m.large != nil {
+			m.msg, m.err = m.large.run(m.command.input.Value())
+		} else {
+			m.msg, m.err = m.doc().run(m.command.input.Value())
+		}
+		return m, nil
+	case key.Matches(msg, keys.SearchCancel):
+		m.command.prompting = false
+		m.command.input.Blur()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.command.input, cmd = m.command.input.Update(msg)
+	return m, cmd
+}
+
+// startCommand opens the command prompt.
+func (m *model) startCommand() tea.Cmd {
+	m.command.prompting = true
+	m.command.input.SetValue("")
+	return m.command.input.Focus()
+}
+
+// run executes a command typed at the prompt. Some commands have something
+// to tell, which is shown in the footer.
+func (d *document) run(cmd string) (string, error) {
+	cmd = strings.TrimSpace(cmd)
+	fields := strings.Fields(cmd)
+	switch {
+	case cmd == "":
+		return "", nil
+	case cmd == "h" || strings.HasPrefix(cmd, "h "):
+		return "", d.gotoHeading(strings.TrimSpace(strings.TrimPrefix(cmd, "h")))
+	case cmd == "marks":
+		return d.listBookmarks(), nil
+	case cmd == "delmarks!":
+		return "", d.deleteBookmarks(nil)
+	case fields[0] == "delmarks":
+		if len(fields) == 1 {
+			return "", fmt.Errorf("usage: :delmarks a b, or :delmarks! to delete all")
+		}
+		return "", d.deleteBookmarks(fields[1:])
+	case strings.HasSuffix(cmd, "%"):
+		pct, err := strconv.Atoi(strings.TrimSuffix(cmd, "%"))
+		if err != nil || pct < 0 || pct > 100 {
+			return "", fmt.Errorf("bad percentage: %s", cmd)
+		}
+		maxOffset := max(0, d.viewport.TotalLineCount()-d.viewport.Height)
+		d.viewport.SetYOffset(maxOffset * pct / 100)
+		return "", nil
+	}
+	n, err := strconv.Atoi(cmd)
+	if err != nil {
+		return "", fmt.Errorf("unknown command: %s", cmd)
+	}
+	if n < 1 {
+		return "", fmt.Errorf("bad line: %d", n)
+	}
+	d.gotoSourceLine(n)
+	return "", nil
+}
+
+// gotoHeading jumps to the first heading starting with the given text, or
+// else to the first one containing it, ignoring case.
+func (d *document) gotoHeading(text string) error {
+	if text == "" {
+		return fmt.Errorf("usage: :h heading")
+	}
+	want := strings.ToLower(text)
+	found := -1
+	for i, h := range d.headings {
+		t := strings.ToLower(h.text)
+		if strings.HasPrefix(t, want) {
+			found = i
+			break
+		}
+		if found < 0 && strings.Contains(t, want) {
+			found = i
+		}
+	}
+	if found < 0 || d.headings[found].line < 0 {
+		return fmt.Errorf("no heading matching %q", text)
+	}
+	d.jumpToHeading(found)
+	return nil
+}
+
+// gutterWidth is the width of the line number gutter for a document with the
+// given number of lines, including the space after the numbers.
+func gutterWidth(lines int) int {
+	return len(strconv.Itoa(max(1, lines))) + 1
+}
+
+// gutterWidth is the width of the line number gutter of the document, or 0
+// if line numbers are off.
+func (d *document) gutterWidth() int {
+	if !d.lineNumbers {
+		return 0
+	}
+	return gutterWidth(d.sourceLineCount())
+}
+
+// addLineNumbers prefixes every line with the number of the source line
+// that starts on it, leaving the gutter blank on the lines that continue a
+// wrapped one. gutter is the width of the gutter.
+func addLineNumbers(lines []string, numbers []int, gutter int) []string {
+	blank := strings.Repeat(" ", gutter)
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if i >= len(numbers) || numbers[i] == 0 {
+			out[i] = blank + l
+			continue
+		}
+		out[i] = lineNumberStyle.Render(fmt.Sprintf("%*d", gutter-1, numbers[i])) + " " + l
+	}
+	return out
+}
+
+// lineRange describes the lines of the file on screen, e.g. "12-40/310".
+func (d *document) lineRange() string {
+	total := d.sourceLineCount()
+	top := d.sourceLineAt(d.viewport.YOffset)
+	bottom := total
+	if last := d.viewport.YOffset + d.viewport.Height; last < len(d.lines) {
+		bottom = d.sourceLineAt(last - 1)
+	}
+	return fmt.Sprintf("%d-%d/%d", top, bottom, total)
+}
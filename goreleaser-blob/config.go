@@ -0,0 +1,434 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultTitle = "Mr. Pager"
+	autoTheme    = "auto"
+)
+
+// config is the user configuration, read from
+// $XDG_CONFIG_HOME/goreleaser-blob/config.yaml. Everything in it is
+// optional. An example:
+//
+//	title: Release Notes
+//	theme: solarized
+//	themes:
+//	  solarized:
+//	    base: dark
+//	    border: double
+//	    title: {foreground: "#b58900", bold: true}
+//	keys:
+//	  quit: [q, ctrl+c]
+//	  next-tab: [L]
+//	  prev-tab: [H]
+//	footer: [section, lines, percent]
+type config struct {
+	Title  string              `yaml:"title"`
+	Theme  string              `yaml:"theme"`
+	Themes map[string]theme    `yaml:"themes"`
+	Keys   map[string][]string `yaml:"keys"`
+
+	// Footer are the fields shown in the footer, in order.
+	Footer []string `yaml:"footer"`
+}
+
+// theme holds the styles of the pager. Custom themes start from one of the
+// built-in themes, given as base, and override what they set.
+type theme struct {
+	Base    string `yaml:"base"`
+	Glamour string `yaml:"glamour"`
+	Border  string `yaml:"border"`
+
+	Title        styleSpec `yaml:"title"`
+	Info         styleSpec `yaml:"info"`
+	Tab          styleSpec `yaml:"tab"`
+	ActiveTab    styleSpec `yaml:"active-tab"`
+	Match        styleSpec `yaml:"match"`
+	CurrentMatch styleSpec `yaml:"current-match"`
+	Link         styleSpec `yaml:"link"`
+	Hover        styleSpec `yaml:"hover"`
+	TOC          styleSpec `yaml:"toc"`
+	TOCCurrent   styleSpec `yaml:"toc-current"`
+	TOCSelected  styleSpec `yaml:"toc-selected"`
+	Notes        styleSpec `yaml:"notes"`
+	LineNumber   styleSpec `yaml:"line-number"`
+	CodeBlock    styleSpec `yaml:"code-block"`
+	FrontMatter  styleSpec `yaml:"front-matter"`
+	Tag          styleSpec `yaml:"tag"`
+	Added        styleSpec `yaml:"added"`
+	Removed      styleSpec `yaml:"removed"`
+	Changed      styleSpec `yaml:"changed"`
+	LogDebug     styleSpec `yaml:"log-debug"`
+	LogInfo      styleSpec `yaml:"log-info"`
+	LogWarn      styleSpec `yaml:"log-warn"`
+	LogError     styleSpec `yaml:"log-error"`
+}
+
+// styleSpec is a lipgloss style as it can be written down in the config.
+// Colors are either hex colors like "#ff8800" or ANSI color numbers.
+type styleSpec struct {
+	Foreground string `yaml:"foreground"`
+	Background string `yaml:"background"`
+	Bold       *bool  `yaml:"bold"`
+	Italic     *bool  `yaml:"italic"`
+	Underline  *bool  `yaml:"underline"`
+	Reverse    *bool  `yaml:"reverse"`
+}
+
+func on() *bool {
+	b := true
+	return &b
+}
+
+// builtinThemes can be selected by name. The "auto" theme picks one of them
+// depending on the terminal background.
+var builtinThemes = map[string]theme{
+	"dark": {
+		Glamour:      "dark",
+		Border:       "rounded",
+		ActiveTab:    styleSpec{Bold: on(), Reverse: on()},
+		Match:        styleSpec{Foreground: "0", Background: "11"},
+		CurrentMatch: styleSpec{Foreground: "0", Background: "208", Bold: on()},
+		Link:         styleSpec{Reverse: on(), Underline: on()},
+		Hover:        styleSpec{Underline: on()},
+		TOCCurrent:   styleSpec{Foreground: "212", Bold: on()},
+		TOCSelected:  styleSpec{Reverse: on()},
+		Notes:        styleSpec{Foreground: "244"},
+		LineNumber:   styleSpec{Foreground: "240"},
+		CodeBlock:    styleSpec{Reverse: on()},
+		Tag:          styleSpec{Foreground: "212"},
+		Added:        styleSpec{Foreground: "2"},
+		Removed:      styleSpec{Foreground: "1"},
+		Changed:      styleSpec{Foreground: "3"},
+		LogDebug:     styleSpec{Foreground: "244"},
+		LogInfo:      styleSpec{Foreground: "12"},
+		LogWarn:      styleSpec{Foreground: "11", Bold: on()},
+		LogError:     styleSpec{Foreground: "9", Bold: on()},
+	},
+	"light": {
+		Glamour:      "light",
+		Border:       "rounded",
+		ActiveTab:    styleSpec{Bold: on(), Reverse: on()},
+		Match:        styleSpec{Foreground: "0", Background: "228"},
+		CurrentMatch: styleSpec{Foreground: "15", Background: "166", Bold: on()},
+		Link:         styleSpec{Reverse: on(), Underline: on()},
+		Hover:        styleSpec{Underline: on()},
+		TOCCurrent:   styleSpec{Foreground: "163", Bold: on()},
+		TOCSelected:  styleSpec{Reverse: on()},
+		Notes:        styleSpec{Foreground: "242"},
+		LineNumber:   styleSpec{Foreground: "248"},
+		CodeBlock:    styleSpec{Reverse: on()},
+		Tag:          styleSpec{Foreground: "163"},
+		Added:        styleSpec{Foreground: "2"},
+		Removed:      styleSpec{Foreground: "1"},
+		Changed:      styleSpec{Foreground: "3"},
+		LogDebug:     styleSpec{Foreground: "245"},
+		LogInfo:      styleSpec{Foreground: "4"},
+		LogWarn:      styleSpec{Foreground: "130", Bold: on()},
+		LogError:     styleSpec{Foreground: "1", Bold: on()},
+	},
+}
+
+var borders = map[string]lipgloss.Border{
+	"rounded": lipgloss.RoundedBorder(),
+	"normal":  lipgloss.NormalBorder(),
+	"thick":   lipgloss.ThickBorder(),
+	"double":  lipgloss.DoubleBorder(),
+	"block":   lipgloss.BlockBorder(),
+	"ascii":   lipgloss.ASCIIBorder(),
+	"hidden":  lipgloss.HiddenBorder(),
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// defaultConfigPath returns where we look for the config file if none was
+// given on the command line.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "goreleaser-blob", "config.yaml")
+}
+
+// loadConfig reads and validates the config file. A missing config file is
+// only an error if it was asked for explicitly.
+func loadConfig(path string, explicit bool) (config, error) {
+	cfg := config{Title: defaultTitle, Theme: autoTheme, Footer: defaultFooter}
+	if path == "" {
+		return cfg, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c config) validate() error {
+	if _, ok := c.Themes[c.Theme]; !ok && !isBuiltinTheme(c.Theme) {
+		return fmt.Errorf("theme: unknown theme %q, use auto, dark, light or one of the themes section", c.Theme)
+	}
+	for name, t := range c.Themes {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("themes.%s.%w", name, err)
+		}
+	}
+	k := defaultKeyMap()
+	if err := k.remap(c.Keys); err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+	for _, f := range c.Footer {
+		if !isFooterField(f) {
+			return fmt.Errorf("footer: unknown field %q, use %s", f, strings.Join(footerFields, ", "))
+		}
+	}
+	return nil
+}
+
+// theme resolves the selected theme, filling in everything a custom theme
+// leaves out from its base.
+func (c config) theme() (theme, error) {
+	name := c.Theme
+	if name == "" {
+		name = autoTheme
+	}
+	t, ok := c.Themes[name]
+	if !ok {
+		if !isBuiltinTheme(name) {
+			return theme{}, fmt.Errorf("theme: unknown theme %q", name)
+		}
+		t = theme{Base: name}
+	}
+
+	base := t.Base
+	if base == "" || base == autoTheme {
+		base = detectStyle()
+	}
+	b, ok := builtinThemes[base]
+	if !ok {
+		return theme{}, fmt.Errorf("themes.%s.base: unknown base %q, use auto, dark or light", name, t.Base)
+	}
+	return b.merge(t), nil
+}
+
+func isBuiltinTheme(name string) bool {
+	_, ok := builtinThemes[name]
+	return ok || name == autoTheme || name == ""
+}
+
+// merge returns t with everything o sets overridden.
+func (t theme) merge(o theme) theme {
+	if o.Glamour != "" {
+		t.Glamour = o.Glamour
+	}
+	if o.Border != "" {
+		t.Border = o.Border
+	}
+	t.Title = t.Title.merge(o.Title)
+	t.Info = t.Info.merge(o.Info)
+	t.Tab = t.Tab.merge(o.Tab)
+	t.ActiveTab = t.ActiveTab.merge(o.ActiveTab)
+	t.Match = t.Match.merge(o.Match)
+	t.CurrentMatch = t.CurrentMatch.merge(o.CurrentMatch)
+	t.Link = t.Link.merge(o.Link)
+	t.Hover = t.Hover.merge(o.Hover)
+	t.TOC = t.TOC.merge(o.TOC)
+	t.TOCCurrent = t.TOCCurrent.merge(o.TOCCurrent)
+	t.TOCSelected = t.TOCSelected.merge(o.TOCSelected)
+	t.Notes = t.Notes.merge(o.Notes)
+	t.LineNumber = t.LineNumber.merge(o.LineNumber)
+	t.CodeBlock = t.CodeBlock.merge(o.CodeBlock)
+	t.FrontMatter = t.FrontMatter.merge(o.FrontMatter)
+	t.Tag = t.Tag.merge(o.Tag)
+	t.Added = t.Added.merge(o.Added)
+	t.Removed = t.Removed.merge(o.Removed)
+	t.Changed = t.Changed.merge(o.Changed)
+	t.LogDebug = t.LogDebug.merge(o.LogDebug)
+	t.LogInfo = t.LogInfo.merge(o.LogInfo)
+	t.LogWarn = t.LogWarn.merge(o.LogWarn)
+	t.LogError = t.LogError.merge(o.LogError)
+	return t
+}
+
+func (s styleSpec) merge(o styleSpec) styleSpec {
+	if o.Foreground != "" {
+		s.Foreground = o.Foreground
+	}
+	if o.Background != "" {
+		s.Background = o.Background
+	}
+	if o.Bold != nil {
+		s.Bold = o.Bold
+	}
+	if o.Italic != nil {
+		s.Italic = o.Italic
+	}
+	if o.Underline != nil {
+		s.Underline = o.Underline
+	}
+	if o.Reverse != nil {
+		s.Reverse = o.Reverse
+	}
+	return s
+}
+
+func (t theme) validate() error {
+	if t.Base != "" && !isBuiltinTheme(t.Base) {
+		return fmt.Errorf("base: unknown base %q, use auto, dark or light", t.Base)
+	}
+	if t.Border != "" {
+		if _, ok := borders[t.Border]; !ok {
+			names := make([]string, 0, len(borders))
+			for name := range borders {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("border: unknown border %q, use one of %s", t.Border, strings.Join(names, ", "))
+		}
+	}
+	if t.Glamour != "" {
+		if _, ok := styles.DefaultStyles[t.Glamour]; !ok {
+			if _, err := os.Stat(t.Glamour); err != nil {
+				return fmt.Errorf("glamour: %q is neither a glamour style nor a style file", t.Glamour)
+			}
+		}
+	}
+	specs := map[string]styleSpec{
+		"title":         t.Title,
+		"info":          t.Info,
+		"tab":           t.Tab,
+		"active-tab":    t.ActiveTab,
+		"match":         t.Match,
+		"current-match": t.CurrentMatch,
+		"link":          t.Link,
+		"hover":         t.Hover,
+		"toc":           t.TOC,
+		"toc-current":   t.TOCCurrent,
+		"toc-selected":  t.TOCSelected,
+		"notes":         t.Notes,
+		"line-number":   t.LineNumber,
+		"code-block":    t.CodeBlock,
+		"front-matter":  t.FrontMatter,
+		"tag":           t.Tag,
+		"added":         t.Added,
+		"removed":       t.Removed,
+		"changed":       t.Changed,
+		"log-debug":     t.LogDebug,
+		"log-info":      t.LogInfo,
+		"log-warn":      t.LogWarn,
+		"log-error":     t.LogError,
+	}
+	for name, s := range specs {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("%s.%w", name, err)
+		}
+	}
+	return nil
+}
+
+func (s styleSpec) validate() error {
+	for field, c := range map[string]string{"foreground": s.Foreground, "background": s.Background} {
+		if c == "" || hexColor.MatchString(c) {
+			continue
+		}
+		if n, err := strconv.Atoi(c); err == nil && n >= 0 && n <= 255 {
+			continue
+		}
+		return fmt.Errorf("%s: invalid color %q, use a hex color like #ff8800 or an ANSI color from 0 to 255", field, c)
+	}
+	return nil
+}
+
+// style applies the spec on top of the given style.
+func (s styleSpec) style(base lipgloss.Style) lipgloss.Style {
+	if s.Foreground != "" {
+		base = base.Foreground(lipgloss.Color(s.Foreground))
+	}
+	if s.Background != "" {
+		base = base.Background(lipgloss.Color(s.Background))
+	}
+	if s.Bold != nil {
+		base = base.Bold(*s.Bold)
+	}
+	if s.Italic != nil {
+		base = base.Italic(*s.Italic)
+	}
+	if s.Underline != nil {
+		base = base.Underline(*s.Underline)
+	}
+	if s.Reverse != nil {
+		base = base.Reverse(*s.Reverse)
+	}
+	return base
+}
+
+// apply replaces the package level styles with the ones of the theme. It has
+// to be called before the program starts.
+func (t theme) apply() {
+	border := borders[t.Border]
+
+	tb := border
+	tb.Right = "├"
+	titleStyle = t.Title.style(lipgloss.NewStyle().BorderStyle(tb).Padding(0, 1))
+
+	ib := border
+	ib.Left = "┤"
+	infoStyle = t.Info.style(lipgloss.NewStyle().BorderStyle(ib).Padding(0, 1))
+
+	tabStyle = t.Tab.style(lipgloss.NewStyle().Padding(0, 1))
+	activeTabStyle = t.ActiveTab.style(tabStyle)
+	matchStyle = t.Match.style(lipgloss.NewStyle())
+	currentMatchStyle = t.CurrentMatch.style(lipgloss.NewStyle())
+	selectedLinkStyle = t.Link.style(lipgloss.NewStyle())
+	hoverStyle = t.Hover.style(lipgloss.NewStyle())
+	sidebarStyle = t.TOC.style(lipgloss.NewStyle().
+		Border(border, false, true, false, false).
+		PaddingRight(1))
+	tocCurrentStyle = t.TOCCurrent.style(lipgloss.NewStyle())
+	tocSelectedStyle = t.TOCSelected.style(lipgloss.NewStyle())
+	lineNumberStyle = t.LineNumber.style(lipgloss.NewStyle())
+	selectedBlockStyle = t.CodeBlock.style(lipgloss.NewStyle())
+	metaStyle = t.FrontMatter.style(lipgloss.NewStyle().
+		Border(border, false, false, true, false).
+		Padding(0, 1))
+	metaTagStyle = t.Tag.style(lipgloss.NewStyle())
+	addedStyle = t.Added.style(lipgloss.NewStyle())
+	removedStyle = t.Removed.style(lipgloss.NewStyle())
+	changedStyle = t.Changed.style(lipgloss.NewStyle())
+	logDebugStyle = t.LogDebug.style(lipgloss.NewStyle())
+	logInfoStyle = t.LogInfo.style(lipgloss.NewStyle())
+	logWarnStyle = t.LogWarn.style(lipgloss.NewStyle())
+	logErrorStyle = t.LogError.style(lipgloss.NewStyle())
+	notesStyle = t.Notes.style(lipgloss.NewStyle().
+		Border(border, true, false, false, false))
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	changedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	fillerStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("238"))
+)
+
+// diffOp is what happened to a line between the old and the new document.
+type diffOp int
+
+const (
+	opEqual diffOp = iota
+	opDelete
+	opInsert
+)
+
+// diffLines computes the shortest edit script from a to b with Myers'
+// algorithm, in its linear space variant: rather than keeping every round to
+// walk the path back, it finds a point in the middle of the path and does the
+// same for both halves. Every op consumes a line of a, of b, or of both.
+func diffLines(a, b []string) []diffOp {
+	return appendDiff(make([]diffOp, 0, len(a)+len(b)), a, b)
+}
+
+// appendDiff appends the edit script from a to b to ops.
+func appendDiff(ops []diffOp, a, b []string) []diffOp {
+	// Lines the documents start and end with need no searching.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	ops = appendOps(ops, opEqual, prefix)
+	switch {
+	case len(a) == 0:
+		ops = appendOps(ops, opInsert, len(b))
+	case len(b) == 0:
+		ops = appendOps(ops, opDelete, len(a))
+	default:
+		x, y := middleSnake(a, b)
+		ops = appendDiff(ops, a[:x], b[:y])
+		ops = appendDiff(ops, a[x:], b[y:])
+	}
+	return appendOps(ops, opEqual, suffix)
+}
+
+func appendOps(ops []diffOp, op diffOp, n int) []diffOp {
+	for ; n > 0; n-- {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// middleSnake searches for the shortest path from the start and from the end
+// at the same time, and returns the point where the two meet, which lies on
+// a shortest path. v holds the furthest x reached on every diagonal, -1 for
+// the diagonals not reached yet.
+func middleSnake(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	off := maxD
+	forward, backward := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[off+1], backward[off+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals that ran off the edit graph are left out from then on.
+	var fStart, fEnd, bStart, bEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			x, y := furthest(forward, off, k, d, func(x, y int) bool { return a[x] == b[y] }, n, m)
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if j := off + delta - k; j >= 0 && j < len(backward) && backward[j] >= 0 && x >= n-backward[j] {
+					return x, y
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			x, y := furthest(backward, off, k, d, func(x, y int) bool { return a[n-1-x] == b[m-1-y] }, n, m)
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				if j := off + delta - k; j >= 0 && j < len(forward) && forward[j] >= 0 && forward[j] >= n-x {
+					return forward[j], forward[j] - (delta - k)
+				}
+			}
+		}
+	}
+	// Not reached for lines that are compared the way they are here, but
+	// replacing all of a with all of b is an edit script too.
+	return n, 0
+}
+
+// furthest takes the step of round d along diagonal k, from the better of
+// the neighbouring diagonals, follows the equal lines from there and
+// records how far it got in v.
+func furthest(v []int, off, k, d int, equal func(x, y int) bool, n, m int) (int, int) {
+	i := off + k
+	var x int
+	if k == -d || (k != d && v[i-1] < v[i+1]) {
+		x = v[i+1]
+	} else {
+		x = v[i-1] + 1
+	}
+	y := x - k
+	for x < n && y < m && equal(x, y) {
+		x++
+		y++
+	}
+	v[i] = x
+	return x, y
+}
+
+// diffKind is how a row of the side by side view changed.
+type diffKind int
+
+const (
+	rowEqual diffKind = iota
+	rowChanged
+	rowRemoved
+	rowAdded
+)
+
+// diffRow is a row of the side by side view. Removed and added lines have
+// no counterpart, so one side is left empty. Blank rows are only spacing and
+// are not counted as changes.
+type diffRow struct {
+	kind        diffKind
+	left, right int
+	blank       bool
+}
+
+// alignRows turns the edit script into rows. Deleted lines directly followed
+// by inserted ones are shown next to each other as changed.
+func alignRows(ops []diffOp) []diffRow {
+	var (
+		rows          []diffRow
+		i, j          int
+		deleted, adds []int
+	)
+	flush := func() {
+		for n := 0; n < max(len(deleted), len(adds)); n++ {
+			switch {
+			case n < len(deleted) && n < len(adds):
+				rows = append(rows, diffRow{kind: rowChanged, left: deleted[n], right: adds[n]})
+			case n < len(deleted):
+				rows = append(rows, diffRow{kind: rowRemoved, left: deleted[n], right: -1})
+			default:
+				rows = append(rows, diffRow{kind: rowAdded, left: -1, right: adds[n]})
+			}
+		}
+		deleted, adds = nil, nil
+	}
+	for _, op := range ops {
+		switch op {
+		case opEqual:
+			flush()
+			rows = append(rows, diffRow{kind: rowEqual, left: i, right: j})
+			i, j = i+1, j+1
+		case opDelete:
+			deleted = append(deleted, i)
+			i++
+		case opInsert:
+			adds = append(adds, j)
+			j++
+		}
+	}
+	flush()
+	return rows
+}
+
+// hunk is a run of rows that differ.
+type hunk struct {
+	start, end int
+}
+
+// diffView shows two documents next to each other, scrolled together.
+type diffView struct {
+	before      *document
+	after       *document
+	left, right viewport.Model
+	rows        []diffRow
+	hunks       []hunk
+
+	// pending are the keys typed so far of a key sequence like "]c".
+	pending string
+}
+
+func newDiffView(before, after *document) *diffView {
+	left, right := viewport.New(0, 0), viewport.New(0, 0)
+	left.KeyMap = keys.viewport()
+	right.KeyMap = keys.viewport()
+	return &diffView{before: before, after: after, left: left, right: right}
+}
+
+// setSize renders both documents to half the width and diffs what is shown,
+// so changes are highlighted the way they end up on screen.
+func (v *diffView) setSize(width, height int, style string) {
+	paneWidth := max(0, (width-1)/2)
+	oldLines := strings.Split(v.before.renderedContent(paneWidth-2, style), "\n")
+	newLines := strings.Split(v.after.renderedContent(paneWidth-2, style), "\n")
+
+	oldPlain, newPlain := plainLines(oldLines), plainLines(newLines)
+	v.rows = alignRows(diffLines(oldPlain, newPlain))
+	for i, r := range v.rows {
+		v.rows[i].blank = (r.left < 0 || oldPlain[r.left] == "") && (r.right < 0 || newPlain[r.right] == "")
+	}
+	v.hunks = nil
+	var blank bool
+	for i, r := range v.rows {
+		switch {
+		case r.kind == rowEqual:
+			continue
+		case len(v.hunks) > 0 && v.hunks[len(v.hunks)-1].end == i:
+			v.hunks[len(v.hunks)-1].end = i + 1
+			blank = blank && r.blank
+		default:
+			// Drop the previous hunk if it only changed the spacing.
+			if len(v.hunks) > 0 && blank {
+				v.hunks = v.hunks[:len(v.hunks)-1]
+			}
+			v.hunks = append(v.hunks, hunk{start: i, end: i + 1})
+			blank = r.blank
+		}
+	}
+	if len(v.hunks) > 0 && blank {
+		v.hunks = v.hunks[:len(v.hunks)-1]
+	}
+
+	var left, right []string
+	for _, r := range v.rows {
+		left = append(left, diffLine(oldLines, r.left, r.kind, removedStyle, paneWidth))
+		right = append(right, diffLine(newLines, r.right, r.kind, addedStyle, paneWidth))
+	}
+	offset := v.left.YOffset
+	v.left.Width, v.left.Height = paneWidth, height
+	v.right.Width, v.right.Height = width-paneWidth-1, height
+	v.left.SetContent(strings.Join(left, "\n"))
+	v.right.SetContent(strings.Join(right, "\n"))
+	v.scrollTo(offset)
+}
+
+func plainLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.TrimSpace(ansi.Strip(l))
+	}
+	return out
+}
+
+// diffLine renders one side of a row with a marker in front. Lines that
+// differ lose their Markdown styling in favour of the diff colors.
+func diffLine(lines []string, i int, kind diffKind, style lipgloss.Style, width int) string {
+	if i < 0 {
+		return fillerStyle.Render(strings.Repeat("╱", width))
+	}
+	line := lines[i]
+	marker := "  "
+	switch kind {
+	case rowChanged:
+		marker = changedStyle.Render("~ ")
+		line = changedStyle.Render(ansi.Strip(line))
+	case rowRemoved, rowAdded:
+		marker = style.Render(markerFor(kind) + " ")
+		line = style.Render(ansi.Strip(line))
+	}
+	return ansi.Truncate(marker+line, width, "")
+}
+
+func markerFor(kind diffKind) string {
+	if kind == rowAdded {
+		return "+"
+	}
+	return "-"
+}
+
+// scrollTo scrolls both sides to the same row.
+func (v *diffView) scrollTo(offset int) {
+	v.left.SetYOffset(offset)
+	v.right.SetYOffset(v.left.YOffset)
+}
+
+// jumpHunk scrolls to the next or previous hunk, keeping a couple of lines
+// of context above it.
+func (v *diffView) jumpHunk(delta int) error {
+	const context = 2
+	if delta > 0 {
+		for _, h := range v.hunks {
+			if top := max(0, h.start-context); top > v.left.YOffset {
+				v.scrollTo(top)
+				return nil
+			}
+		}
+		return fmt.Errorf("no more changes")
+	}
+	for i := len(v.hunks) - 1; i >= 0; i-- {
+		if top := max(0, v.hunks[i].start-context); top < v.left.YOffset {
+			v.scrollTo(top)
+			return nil
+		}
+	}
+	return fmt.Errorf("no earlier changes")
+}
+
+// currentHunk returns the number of the last hunk that starts on or above
+// the screen, counting from 1.
+func (v *diffView) currentHunk() int {
+	cur := 0
+	for i, h := range v.hunks {
+		if h.start < v.left.YOffset+v.left.Height {
+			cur = i + 1
+		}
+	}
+	return cur
+}
+
+// summary counts the changed lines, e.g. "+3 -1 ~7, change 2/5".
+func (v *diffView) summary() string {
+	var added, removed, changed int
+	for _, r := range v.rows {
+		if r.blank {
+			continue
+		}
+		switch r.kind {
+		case rowAdded:
+			added++
+		case rowRemoved:
+			removed++
+		case rowChanged:
+			changed++
+		}
+	}
+	cur := v.currentHunk()
+	switch {
+	case len(v.hunks) == 0:
+		return "no changes"
+	case cur == 0:
+		return fmt.Sprintf("+%d -%d ~%d, %d changes", added, removed, changed, len(v.hunks))
+	}
+	return fmt.Sprintf("+%d -%d ~%d, change %d/%d", added, removed, changed, cur, len(v.hunks))
+}
+
+// view puts both sides next to each other.
+func (v *diffView) view() string {
+	sep := strings.TrimSuffix(strings.Repeat("│\n", v.left.Height), "\n")
+	return lipgloss.JoinHorizontal(lipgloss.Top, v.left.View(), sep, v.right.View())
+}
+
+// updateDiff handles messages in --diff mode.
+func (m model) updateDiff(msg tea.Msg) (tea.Model, tea.Cmd) {
+	v := m.diff
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.err = nil
+		seq := v.pending + msg.String()
+		v.pending = ""
+		next, nextPrefix := sequence(keys.NextChange, seq)
+		prev, prevPrefix := sequence(keys.PrevChange, seq)
+		switch {
+		case next:
+			m.err = v.jumpHunk(1)
+			return m, nil
+		case prev:
+			m.err = v.jumpHunk(-1)
+			return m, nil
+		case nextPrefix || prevPrefix:
+			v.pending = seq
+			return m, nil
+		case key.Matches(msg, keys.Quit):
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	v.left, cmd = v.left.Update(msg)
+	v.right.SetYOffset(v.left.YOffset)
+	return m, cmd
+}
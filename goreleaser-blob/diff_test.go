@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyDiff checks that ops is an edit script from a to b and returns how
+// many lines it keeps.
+func applyDiff(t *testing.T, a, b []string, ops []diffOp) int {
+	t.Helper()
+	var i, j, kept int
+	for _, op := range ops {
+		switch op {
+		case opEqual:
+			if i >= len(a) || j >= len(b) || a[i] != b[j] {
+				t.Fatalf("%q to %q: %v keeps line %d of a as line %d of b, which differ", a, b, ops, i, j)
+			}
+			i, j, kept = i+1, j+1, kept+1
+		case opDelete:
+			i++
+		case opInsert:
+			j++
+		}
+	}
+	if i != len(a) || j != len(b) {
+		t.Fatalf("%q to %q: %v consumes %d and %d lines, want %d and %d", a, b, ops, i, j, len(a), len(b))
+	}
+	return kept
+}
+
+// lcs is the length of the longest common subsequence of a and b, the
+// quadratic way.
+func lcs(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		// want has a character per op: = for kept, - for deleted and +
+		// for inserted lines.
+		want string
+	}{
+		{"empty", "", "", ""},
+		{"identical", "a b c", "a b c", "==="},
+		{"all inserted", "", "a b", "++"},
+		{"all deleted", "a b", "", "--"},
+		{"inserted in the middle", "a c", "a b c", "=+="},
+		{"deleted in the middle", "a b c", "a c", "=-="},
+		{"inserted around", "b", "a b c", "+=+"},
+		{"deleted around", "a b c", "b", "-=-"},
+		{"insertions", "a c e", "a b c d e", "=+=+="},
+		{"deletions", "a b c d e", "a c e", "=-=-="},
+		{"replaced", "a b", "c d", "--++"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			ops := diffLines(a, b)
+			applyDiff(t, a, b, ops)
+			var got strings.Builder
+			for _, op := range ops {
+				got.WriteByte("=-+"[op])
+			}
+			if got.String() != tt.want {
+				t.Errorf("got %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		// Few different lines, so there is a lot to match up.
+		s := make([]string, r.Intn(40))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(4)))
+		}
+		return s
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := lines(), lines()
+		ops := diffLines(a, b)
+		if kept, want := applyDiff(t, a, b, ops), lcs(a, b); kept != want {
+			t.Fatalf("%q to %q: %v keeps %d lines, want %d", a, b, ops, kept, want)
+		}
+	}
+}
+
+// TestDiffKeepsPositions checks that --diff, which never scrolls the
+// documents themselves, leaves the reading positions saved for them alone.
+func TestDiffKeepsPositions(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "old.md"), filepath.Join(dir, "new.md")}
+	for i, path := range paths {
+		content := "# Notes\n\n" + strings.Repeat("A line of notes.\n\n", 50+i)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := func(diff bool, scroll int) {
+		t.Helper()
+		st, err := openStore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := model{width: 80, height: 24, style: "dark", store: st}
+		var docs []*document
+		for _, path := range paths {
+			d, err := loadDocument(path, newSearch(false, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.opened(d)
+			docs = append(docs, d)
+			m.tabs = append(m.tabs, newTab(d))
+		}
+		if diff {
+			m.diff = newDiffView(docs[0], docs[1])
+		}
+		m.layout()
+		for _, d := range docs {
+			d.viewport.SetYOffset(scroll)
+		}
+		if err := m.store.save(m.savedDocuments()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := func() map[string]*docState {
+		t.Helper()
+		st, err := openStore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.docs
+	}
+
+	session(true, 0)
+	if got := saved(); len(got) != 0 {
+		t.Fatalf("--diff saved positions for %d documents", len(got))
+	}
+
+	session(false, 10)
+	want := saved()
+	if len(want) != 2 || want[paths[0]].Position.Line != 10 {
+		t.Fatalf("got %d saved documents, want 2 at line 10", len(want))
+	}
+	session(true, 0)
+	if got := saved(); !reflect.DeepEqual(got, want) {
+		t.Errorf("--diff changed the saved positions")
+	}
+}
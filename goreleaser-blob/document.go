@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// stdinName is the file argument that makes us read the document from
+// standard input.
+const stdinName = "-"
+
+// document is a single file opened in the pager. Every document keeps its
+// own viewport and search, so switching between tabs preserves the scroll
+// position.
+type document struct {
+	name       string
+	path       string
+	content    string
+	body       string
+	meta       *frontMatter
+	lines      []string
+	headings   []heading
+	links      []link
+	codeBlocks []codeBlock
+	viewport   viewport.Model
+
+	// folds are the sections of all headings of the body, folded those
+	// the reader folded with a click on their heading.
+	folds  []fold
+	folded map[int]bool
+
+	// sourceLines[i] is the line of the file that starts on rendered line
+	// i, or 0 if none does, see numberLines.
+	sourceLines []int
+
+	// words[i] is the number of words above line i of the body, see
+	// countWords.
+	words []int
+
+	selectedLink  int
+	selectedBlock int
+	lineNumbers   bool
+
+	// The link or heading under the mouse pointer, or -1.
+	hoverLink    int
+	hoverHeading int
+
+	// Without wrap, lines are as long as they are and xOffset is the first
+	// column on screen.
+	wrap      bool
+	xOffset   int
+	textWidth int
+	longest   int
+
+	// bookmarks are kept across runs, see store. restore is the position
+	// to scroll to once the document has been rendered, changed is set if
+	// the document changed since we last saw it.
+	bookmarks map[string]mark
+	restore   *mark
+	changed   bool
+
+	// deck is only set in --slides mode.
+	deck *deck
+
+	// In --log mode the document is a log rather than Markdown. logSource
+	// has the log line of every rendered line.
+	log       bool
+	logLines  []logLine
+	logSource []int
+	hideDebug bool
+	hideInfo  bool
+
+	search     search
+	searchFrom int
+
+	reloadedAt time.Time
+	reloadErr  error
+}
+
+func newDocument(name, content string, s search) *document {
+	vp := viewport.New(0, 0)
+	vp.HighPerformanceRendering = useHighPerformanceRenderer
+	vp.KeyMap = keys.viewport()
+	d := &document{
+		name:     name,
+		viewport: vp,
+		search:   s,
+
+		selectedLink:  -1,
+		selectedBlock: -1,
+		hoverLink:     -1,
+		hoverHeading:  -1,
+		wrap:          true,
+		bookmarks:     map[string]mark{},
+	}
+	d.setContent(content)
+	return d
+}
+
+// setContent replaces the content of the document, splitting off the front
+// matter, or into log lines in --log mode.
+func (d *document) setContent(content string) {
+	d.content = content
+	if d.log {
+		d.meta, d.body = nil, content
+		d.logLines = parseLog(content)
+	} else {
+		d.meta, d.body = splitFrontMatter(content)
+	}
+	d.words = countWords(d.body)
+}
+
+// loadDocument reads the document from the given path, from a bucket if the
+// path is a blob URL, or from standard input if the path is "-".
+func loadDocument(path string, s search) (*document, error) {
+	if isBlobURL(path) {
+		return loadBlobDocument(path, s)
+	}
+	if path == stdinName {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		return newDocument("stdin", string(content), s), nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := newDocument(filepath.Base(path), string(content), s)
+	d.path = path
+	return d, nil
+}
+
+// setSize resizes the viewport and reflows the document to the new width.
+// Glamour does the word wrapping for us, so this has to happen on every
+// resize.
+func (d *document) setSize(width, height int, style string) {
+	// Logs stay at the line on top when lines are filtered out or wrap
+	// differently.
+	anchor := -1
+	if d.log && len(d.logSource) > 0 {
+		anchor = d.logAnchor()
+	}
+	d.viewport.Width = width
+	d.viewport.Height = height
+	d.folds = nil
+	if !d.log && d.deck == nil {
+		d.folds = parseFolds(d.body)
+	}
+	d.textWidth = width - d.gutterWidth()
+	d.lines = strings.Split(d.renderedContent(d.textWidth, style), "\n")
+	d.numberLines()
+	d.longest = 0
+	for _, l := range d.lines {
+		d.longest = max(d.longest, ansi.StringWidth(l))
+	}
+	d.scrollColumns(0)
+	d.headings, d.links, d.codeBlocks = nil, nil, nil
+	if !d.log {
+		d.headings = parseHeadings(d.source())
+		locateHeadings(d.headings, d.lines)
+		d.numberFolds()
+		d.links = locateLinks(parseLinks(d.source()), d.lines)
+		d.codeBlocks = locateCodeBlocks(parseCodeBlocks(d.source()), d.lines)
+	}
+	if d.selectedLink >= len(d.links) {
+		d.selectedLink = -1
+	}
+	if d.selectedBlock >= len(d.codeBlocks) {
+		d.selectedBlock = -1
+	}
+	d.hoverLink, d.hoverHeading = -1, -1
+	d.refreshSearch()
+	if anchor >= 0 {
+		d.scrollToLog(anchor)
+	}
+	if d.restore != nil {
+		d.viewport.SetYOffset(d.restore.line(len(d.lines)))
+		d.restore = nil
+	}
+}
+
+// updateContent hands the rendered lines to the viewport, with the search
+// matches and the selected link highlighted, and cut to the columns on screen
+// if wrap is off.
+func (d *document) updateContent() {
+	lines := d.markFolds(d.lines)
+	lines = d.search.highlight(lines)
+	lines = d.highlightHover(lines)
+	lines = d.highlightLink(lines)
+	lines = d.highlightBlock(lines)
+	if !d.wrap {
+		cut := make([]string, len(lines))
+		for i, l := range lines {
+			cut[i] = ansi.Cut(l, d.xOffset, d.xOffset+d.textWidth)
+		}
+		lines = cut
+	}
+	if d.lineNumbers {
+		lines = addLineNumbers(lines, d.sourceLines, d.gutterWidth())
+	}
+	d.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+// horizontalStep is how many columns the left and right keys and the
+// horizontal mouse wheel scroll.
+const horizontalStep = 8
+
+// scrollColumns scrolls the document horizontally by delta columns, as far
+// as the longest line goes.
+func (d *document) scrollColumns(delta int) {
+	x := min(d.xOffset+delta, d.longest-d.textWidth)
+	if d.wrap {
+		x = 0
+	}
+	if x = max(0, x); x != d.xOffset {
+		d.xOffset = x
+		d.updateContent()
+	}
+}
+
+// showColumns scrolls horizontally so that the columns from start to end are
+// on screen, or at least where they start.
+func (d *document) showColumns(start, end int) {
+	switch {
+	case start < d.xOffset:
+		d.scrollColumns(start - d.xOffset)
+	case end > d.xOffset+d.textWidth:
+		d.scrollColumns(min(start, end-d.textWidth) - d.xOffset)
+	}
+}
+
+// source is the Markdown that is shown, which is the body of the document
+// without its front matter and folded sections, or the current slide in
+// --slides mode.
+func (d *document) source() string {
+	if d.deck != nil {
+		return d.deck.slide().body
+	}
+	if len(d.folded) > 0 {
+		src, _ := foldSource(d.body, d.folds, d.folded)
+		return src
+	}
+	return d.body
+}
+
+// renderedContent renders the Markdown document, or the log in --log mode,
+// for the given width, or without wrapping it at all if wrap is off. If
+// rendering fails, we fall back to showing the raw document.
+func (d *document) renderedContent(width int, style string) string {
+	wrapWidth := width
+	if !d.wrap {
+		wrapWidth = 0
+	}
+	if d.log {
+		return d.renderLog(wrapWidth)
+	}
+	out, err := renderMarkdown(d.source(), wrapWidth, style)
+	if err != nil {
+		return d.source()
+	}
+	if d.deck != nil {
+		return center(out, width, d.viewport.Height)
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// The formats of --render-to.
+const (
+	formatANSI  = "ansi"
+	formatPlain = "plain"
+	formatHTML  = "html"
+)
+
+// Exit codes, so scripts can tell a missing document from a broken one.
+// Usage and config errors exit with 1, like kingpin does.
+const (
+	exitLoadFailed   = 2
+	exitRenderFailed = 3
+)
+
+// defaultRenderWidth is the width we wrap to when there is no terminal to
+// ask.
+const defaultRenderWidth = 80
+
+// failure is an error that ends the program with a specific exit code.
+type failure struct {
+	code int
+	err  error
+}
+
+func (f failure) Error() string { return f.err.Error() }
+
+// isTerminal reports whether f is attached to a terminal. If stdout is not,
+// there is nobody to page for and we print the rendered documents instead.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(f.Fd())
+}
+
+// renderWidth returns the width to render to, which is the width of the
+// terminal if there is one.
+func renderWidth(f *os.File) int {
+	if w, _, err := term.GetSize(f.Fd()); err == nil && w > 0 {
+		return w
+	}
+	return defaultRenderWidth
+}
+
+// export renders all documents to w in the given format, one after another.
+// Logs are printed without the control sequences that would mess up a
+// terminal, and as preformatted text in HTML.
+func export(w io.Writer, paths []string, format string, width int, style string, log bool) error {
+	var docs []*document
+	for _, path := range paths {
+		if isDir(path) {
+			return failure{exitLoadFailed, fmt.Errorf("%s is a directory, only files can be rendered", path)}
+		}
+		// Rendering would mean reading the whole file into memory, which
+		// is what paging large files avoids.
+		if isLargeFile(path) {
+			return failure{exitLoadFailed, fmt.Errorf("%s is too large to render, it can only be paged in a terminal", path)}
+		}
+		d, err := loadDocument(path, newSearch(false, false))
+		if err != nil {
+			return failure{exitLoadFailed, fmt.Errorf("could not load document: %w", err)}
+		}
+		if log {
+			d.log = true
+			d.wrap = false
+			d.setContent(d.content)
+		}
+		docs = append(docs, d)
+	}
+
+	if format == formatANSI {
+		// Glamour always colors its output, the log levels should too.
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	switch format {
+	case formatHTML:
+		out, err = exportHTML(docs)
+	default:
+		out, err = exportText(docs, format, width, style)
+	}
+	if err != nil {
+		return failure{exitRenderFailed, err}
+	}
+	if _, err := w.Write(out); err != nil {
+		return failure{exitRenderFailed, err}
+	}
+	return nil
+}
+
+// exportText renders the documents the way the pager shows them, with the
+// title, date and tags of their front matter on top. The plain format drops
+// all styling, which is what CI logs and pipes want.
+func exportText(docs []*document, format string, width int, style string) ([]byte, error) {
+	var b bytes.Buffer
+	for i, d := range docs {
+		var (
+			out string
+			err error
+		)
+		if d.log {
+			out = d.renderLog(0) + "\n"
+		} else if out, err = renderMarkdown(d.body, width, style); err != nil {
+			return nil, fmt.Errorf("could not render %s: %w", d.name, err)
+		} else if meta := d.metaView(width, 0, false); meta != "" {
+			// The front matter is not part of the body, the header the
+			// pager shows for it goes on top.
+			out = meta + "\n" + out
+		}
+		if format == formatPlain {
+			lines := strings.Split(ansi.Strip(out), "\n")
+			for j, l := range lines {
+				lines[j] = strings.TrimRight(l, " ")
+			}
+			out = strings.Join(lines, "\n")
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(out)
+	}
+	return b.Bytes(), nil
+}
+
+// exportHTML renders the documents into a standalone HTML page, with one
+// article per document.
+func exportHTML(docs []*document) ([]byte, error) {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	title := docs[0].meta.title()
+	if h := parseHeadings(docs[0].body); title == "" && !docs[0].log && len(h) > 0 {
+		title = h[0].text
+	}
+	if title == "" {
+		title = docs[0].name
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString("</head>\n<body>\n")
+	for _, d := range docs {
+		b.WriteString("<article>\n")
+		if d.log {
+			fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(ansi.Strip(d.renderLog(0))))
+		} else if err := md.Convert([]byte(d.body), &b); err != nil {
+			return nil, fmt.Errorf("could not render %s: %w", d.name, err)
+		}
+		b.WriteString("</article>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// foldMarker is appended to the headings of folded sections.
+const foldMarker = " …"
+
+// fold is the section a heading starts, as byte offsets into the Markdown
+// source: the heading starts at start, and its section runs from body up to
+// end, where the next heading of the same or a higher level starts. Headings
+// nested in lists or quotes can't be folded and have an empty section.
+type fold struct {
+	start, body, end int
+}
+
+func (f fold) foldable() bool {
+	return f.body < f.end
+}
+
+// parseFolds returns the section of every heading of the source, in the same
+// order as parseHeadings.
+func parseFolds(source string) []fold {
+	src := []byte(source)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	var (
+		folds  []fold
+		levels []int
+	)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		f := fold{start: -1}
+		if lines := h.Lines(); lines.Len() > 0 {
+			f.start = bytes.LastIndexByte(src[:lines.At(0).Start], '\n') + 1
+			f.body = lineEnd(src, lines.At(lines.Len()-1).Stop-1)
+			if strings.TrimLeft(string(src[f.start:lines.At(0).Start]), " ") == "" {
+				// A setext heading, which is underlined on the next line.
+				f.body = lineEnd(src, f.body)
+			}
+			f.end = f.body
+		}
+		if h.Parent() == doc && f.start >= 0 {
+			f.end = len(src)
+			// Close the open sections of this level and below.
+			for i := len(folds) - 1; i >= 0; i-- {
+				if folds[i].end != len(src) || folds[i].body == len(src) {
+					continue
+				}
+				if levels[i] < h.Level {
+					break
+				}
+				folds[i].end = f.start
+			}
+		}
+		folds = append(folds, f)
+		levels = append(levels, h.Level)
+		return ast.WalkSkipChildren, nil
+	})
+	return folds
+}
+
+// lineEnd returns the offset right after the line the offset is on.
+func lineEnd(src []byte, off int) int {
+	if off < 0 {
+		return 0
+	}
+	if off >= len(src) {
+		return len(src)
+	}
+	if i := bytes.IndexByte(src[off:], '\n'); i >= 0 {
+		return off + i + 1
+	}
+	return len(src)
+}
+
+// foldSource cuts the sections of the folded headings out of the source,
+// keeping the headings themselves. It also returns the headings that are
+// left, as indexes into folds.
+func foldSource(source string, folds []fold, folded map[int]bool) (string, []int) {
+	var (
+		b    strings.Builder
+		kept []int
+		pos  int
+	)
+	for i, f := range folds {
+		if f.start >= 0 && f.start < pos {
+			// In a section that is folded already.
+			continue
+		}
+		kept = append(kept, i)
+		if folded[i] && f.foldable() && f.end <= len(source) {
+			b.WriteString(source[pos:f.body])
+			pos = f.end
+		}
+	}
+	b.WriteString(source[pos:])
+	return b.String(), kept
+}
+
+// unfoldOffset maps an offset into the source foldSource returns back to the
+// source it was cut from.
+func unfoldOffset(off int, folds []fold, folded map[int]bool) int {
+	pos, out := 0, 0
+	for i, f := range folds {
+		if f.start >= 0 && f.start < pos {
+			continue
+		}
+		if folded[i] && f.foldable() {
+			if off < out+f.body-pos {
+				break
+			}
+			out += f.body - pos
+			pos = f.end
+		}
+	}
+	return pos + off - out
+}
+
+// toggleFold folds the section of the heading, or unfolds it again, keeping
+// the heading in the same place on screen.
+func (d *document) toggleFold(i int, style string) {
+	h := d.headings[i]
+	if h.fold < 0 || h.fold >= len(d.folds) || !d.folds[h.fold].foldable() {
+		return
+	}
+	row := h.line - d.viewport.YOffset
+	if d.folded == nil {
+		d.folded = map[int]bool{}
+	}
+	if d.folded[h.fold] {
+		delete(d.folded, h.fold)
+	} else {
+		d.folded[h.fold] = true
+	}
+	d.setSize(d.viewport.Width, d.viewport.Height, style)
+	for _, nh := range d.headings {
+		if nh.fold == h.fold && nh.line >= 0 {
+			d.viewport.SetYOffset(max(0, nh.line-row))
+		}
+	}
+}
+
+// markFolds returns a copy of lines with the headings of folded sections
+// marked.
+func (d *document) markFolds(lines []string) []string {
+	if len(d.folded) == 0 {
+		return lines
+	}
+	out := make([]string, len(lines))
+	copy(out, lines)
+	for _, h := range d.headings {
+		if h.line < 0 || h.line >= len(out) || !d.folded[h.fold] {
+			continue
+		}
+		l := out[h.line]
+		end := ansi.StringWidth(strings.TrimRight(ansi.Strip(l), " "))
+		out[h.line] = ansi.Truncate(l, end, "") + foldMarker + ansi.Cut(l, end+ansi.StringWidth(foldMarker), ansi.StringWidth(l))
+	}
+	return out
+}
+
+// numberFolds tells the headings which section of the document they start.
+func (d *document) numberFolds() {
+	_, kept := foldSource(d.body, d.folds, d.folded)
+	for i := range d.headings {
+		d.headings[i].fold = -1
+		if len(kept) == len(d.headings) {
+			d.headings[i].fold = kept[i]
+		}
+	}
+}
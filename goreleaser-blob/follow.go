@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadMsg is sent into the Bubble Tea loop whenever a followed file was
+// rewritten on disk.
+type reloadMsg struct {
+	path    string
+	content string
+	err     error
+	at      time.Time
+}
+
+// follower watches the documents' files for changes. We watch the parent
+// directories rather than the files themselves, as most editors and tools
+// replace a file by renaming a new one over it, which would otherwise end
+// the watch.
+type follower struct {
+	watcher *fsnotify.Watcher
+	files   map[string]bool
+}
+
+func newFollower(docs []*document) (*follower, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	f := &follower{watcher: w, files: map[string]bool{}}
+	for _, d := range docs {
+		if err := f.add(d); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// add starts following the given document, e.g. after following a link.
+func (f *follower) add(d *document) error {
+	if d.path == "" || f.files[d.path] {
+		// There is nothing to follow for stdin.
+		return nil
+	}
+	f.files[d.path] = true
+	return f.watcher.Add(filepath.Dir(d.path))
+}
+
+// wait blocks until one of the followed files changes and returns its new
+// content as a reloadMsg. It has to be issued again after every message.
+func (f *follower) wait() tea.Cmd {
+	return func() tea.Msg {
+		for {
+			select {
+			case ev, ok := <-f.watcher.Events:
+				if !ok {
+					return nil
+				}
+				if !f.files[ev.Name] || !(ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create)) {
+					continue
+				}
+				content, err := os.ReadFile(ev.Name)
+				return reloadMsg{path: ev.Name, content: string(content), err: err, at: time.Now()}
+			case err, ok := <-f.watcher.Errors:
+				if !ok {
+					return nil
+				}
+				return reloadMsg{err: err, at: time.Now()}
+			}
+		}
+	}
+}
+
+// reload swaps in the new content of the document, which is rendered on the
+// next layout. The scroll position is kept, unless the viewport was at the
+// bottom, in which case we stick to the bottom like tail -f does.
+func (d *document) reload(content string) {
+	pos := mark{Line: d.viewport.YOffset}
+	if d.viewport.AtBottom() {
+		pos.Line = math.MaxInt
+	}
+	d.setContent(content)
+	if d.deck != nil {
+		d.deck.reload(d.body)
+	}
+	d.restore = &pos
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	metaStyle = lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder(), false, false, true, false).
+			Padding(0, 1)
+
+	metaTitleStyle = lipgloss.NewStyle().Bold(true)
+	metaTagStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+)
+
+// frontMatter is the YAML or TOML metadata block at the top of a document.
+type frontMatter struct {
+	raw    string
+	fields map[string]any
+}
+
+// frontMatterDelims are the delimiters of the formats we understand, "---"
+// for YAML and "+++" for TOML, as Hugo, Jekyll and Hashnode use them.
+var frontMatterDelims = map[string]func(string) (map[string]any, error){
+	"---": func(s string) (map[string]any, error) {
+		fields := map[string]any{}
+		return fields, yaml.Unmarshal([]byte(s), &fields)
+	},
+	"+++": func(s string) (map[string]any, error) {
+		fields := map[string]any{}
+		_, err := toml.Decode(s, &fields)
+		return fields, err
+	},
+}
+
+// splitFrontMatter separates the front matter from the body of a document.
+// A block that does not parse into a map is not front matter, but e.g. a
+// horizontal rule followed by a heading, and is left alone.
+func splitFrontMatter(content string) (*frontMatter, string) {
+	first, rest, ok := strings.Cut(content, "\n")
+	delim := strings.TrimSpace(first)
+	parse, known := frontMatterDelims[delim]
+	if !ok || !known {
+		return nil, content
+	}
+
+	var raw []string
+	for {
+		line, tail, more := strings.Cut(rest, "\n")
+		if strings.TrimSpace(line) == delim || (delim == "---" && strings.TrimSpace(line) == "...") {
+			rest = tail
+			break
+		}
+		if !more {
+			return nil, content
+		}
+		raw = append(raw, line)
+		rest = tail
+	}
+
+	fm := &frontMatter{raw: strings.Join(raw, "\n")}
+	fields, err := parse(fm.raw)
+	if err != nil || len(fields) == 0 {
+		return nil, content
+	}
+	fm.fields = fields
+	return fm, strings.TrimLeft(rest, "\n")
+}
+
+// title returns the title of the document, if the front matter has one.
+func (fm *frontMatter) title() string {
+	if fm == nil {
+		return ""
+	}
+	s, _ := fm.fields["title"].(string)
+	return strings.TrimSpace(s)
+}
+
+// date returns the date of the document formatted as a day.
+func (fm *frontMatter) date() string {
+	if fm == nil {
+		return ""
+	}
+	switch d := fm.fields["date"].(type) {
+	case time.Time:
+		return d.Format("2006-01-02")
+	case nil:
+		return ""
+	default:
+		return strings.TrimSpace(fmt.Sprint(d))
+	}
+}
+
+// tags returns the tags of the document. They can be a list or a comma
+// separated string.
+func (fm *frontMatter) tags() []string {
+	if fm == nil {
+		return nil
+	}
+	var tags []string
+	switch t := fm.fields["tags"].(type) {
+	case string:
+		for _, tag := range strings.Split(t, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	case []any:
+		for _, tag := range t {
+			tags = append(tags, fmt.Sprint(tag))
+		}
+	}
+	return tags
+}
+
+// metaView renders the front matter panel under the title bar. By default
+// it shows the title, date and tags, raw shows the whole front matter, cut
+// down to half the screen.
+func (d *document) metaView(width, height int, raw bool) string {
+	fm := d.meta
+	if fm == nil {
+		return ""
+	}
+	inner := max(0, width-metaStyle.GetHorizontalFrameSize())
+
+	var lines []string
+	if raw {
+		lines = strings.Split(strings.TrimRight(fm.raw, "\n"), "\n")
+		if limit := max(1, height/2); len(lines) > limit {
+			lines = append(lines[:limit-1], "…")
+		}
+	} else {
+		var parts []string
+		if t := fm.title(); t != "" {
+			parts = append(parts, metaTitleStyle.Render(t))
+		}
+		if date := fm.date(); date != "" {
+			parts = append(parts, date)
+		}
+		tags := fm.tags()
+		for i, t := range tags {
+			tags[i] = metaTagStyle.Render("#" + t)
+		}
+		if len(tags) > 0 {
+			parts = append(parts, strings.Join(tags, " "))
+		}
+		if len(parts) == 0 {
+			return ""
+		}
+		lines = []string{strings.Join(parts, " · ")}
+	}
+	for i, l := range lines {
+		lines[i] = ansi.Truncate(l, inner, "…")
+	}
+	return metaStyle.Width(width).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,53 @@
+package main
+
+// tab is a tab of the pager. Following a link replaces the document shown in
+// the tab, and the documents we came from are kept on the back stack. As
+// every document keeps its own viewport, going back restores the scroll
+// position we left it at.
+type tab struct {
+	doc     *document
+	back    []*document
+	forward []*document
+}
+
+func newTab(d *document) *tab {
+	return &tab{doc: d}
+}
+
+// documents returns the documents of the tab, the one shown and those in its
+// history.
+func (t *tab) documents() []*document {
+	docs := append([]*document(nil), t.back...)
+	docs = append(docs, t.doc)
+	return append(docs, t.forward...)
+}
+
+// visit shows the given document and drops the forward history, like a
+// browser does.
+func (t *tab) visit(d *document) {
+	t.back = append(t.back, t.doc)
+	t.forward = nil
+	t.doc = d
+}
+
+// goBack returns to the previous document, if there is one.
+func (t *tab) goBack() bool {
+	if len(t.back) == 0 {
+		return false
+	}
+	t.forward = append(t.forward, t.doc)
+	t.doc = t.back[len(t.back)-1]
+	t.back = t.back[:len(t.back)-1]
+	return true
+}
+
+// goForward undoes goBack.
+func (t *tab) goForward() bool {
+	if len(t.forward) == 0 {
+		return false
+	}
+	t.back = append(t.back, t.doc)
+	t.doc = t.forward[len(t.forward)-1]
+	t.forward = t.forward[:len(t.forward)-1]
+	return true
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+// keyMap holds the key bindings of all actions of the pager. The bindings can
+// be remapped in the config file, using the action names of keyActions.
+type keyMap struct {
+	Quit      key.Binding
+	Search    key.Binding
+	NextMatch key.Binding
+	PrevMatch key.Binding
+	NextTab   key.Binding
+	PrevTab   key.Binding
+	TOC       key.Binding
+	TOCJump   key.Binding
+	NextLink  key.Binding
+	PrevLink  key.Binding
+	OpenLink  key.Binding
+	Back      key.Binding
+	Forward   key.Binding
+	Browse    key.Binding
+
+	Command     key.Binding
+	LineNumbers key.Binding
+	FrontMatter key.Binding
+	Wrap        key.Binding
+
+	NextBlock key.Binding
+	Yank      key.Binding
+
+	// In --log mode.
+	HideDebug key.Binding
+	HideInfo  key.Binding
+
+	// In --diff mode. These are key sequences, typed one key after the
+	// other.
+	NextChange key.Binding
+	PrevChange key.Binding
+
+	// Followed by the name of the bookmark.
+	SetBookmark  key.Binding
+	GotoBookmark key.Binding
+
+	// In --slides mode.
+	NextSlide key.Binding
+	PrevSlide key.Binding
+	Notes     key.Binding
+
+	// While the search prompt is open.
+	SearchConfirm key.Binding
+	SearchCancel  key.Binding
+	ToggleRegex   key.Binding
+	ToggleCase    key.Binding
+
+	// Scrolling, handed to the viewports.
+	PageDown     key.Binding
+	PageUp       key.Binding
+	HalfPageDown key.Binding
+	HalfPageUp   key.Binding
+	Down         key.Binding
+	Up           key.Binding
+	Left         key.Binding
+	Right        key.Binding
+}
+
+// keys are the active key bindings. They are replaced by the ones from the
+// config file at startup.
+var keys = defaultKeyMap()
+
+func defaultKeyMap() keyMap {
+	vp := viewport.DefaultKeyMap()
+	return keyMap{
+		Quit:      key.NewBinding(key.WithKeys("ctrl+c", "q", "esc")),
+		Search:    key.NewBinding(key.WithKeys("/")),
+		NextMatch: key.NewBinding(key.WithKeys("n")),
+		PrevMatch: key.NewBinding(key.WithKeys("N")),
+		NextTab:   key.NewBinding(key.WithKeys(">")),
+		PrevTab:   key.NewBinding(key.WithKeys("<")),
+		TOC:       key.NewBinding(key.WithKeys("t")),
+		TOCJump:   key.NewBinding(key.WithKeys("enter")),
+		NextLink:  key.NewBinding(key.WithKeys("tab")),
+		PrevLink:  key.NewBinding(key.WithKeys("shift+tab")),
+		OpenLink:  key.NewBinding(key.WithKeys("enter")),
+		Back:      key.NewBinding(key.WithKeys("backspace", "alt+left")),
+		Forward:   key.NewBinding(key.WithKeys("alt+right")),
+		Browse:    key.NewBinding(key.WithKeys("B")),
+
+		Command:     key.NewBinding(key.WithKeys(":")),
+		LineNumbers: key.NewBinding(key.WithKeys("#")),
+		FrontMatter: key.NewBinding(key.WithKeys("M")),
+		Wrap:        key.NewBinding(key.WithKeys("w")),
+
+		NextBlock: key.NewBinding(key.WithKeys("c")),
+		Yank:      key.NewBinding(key.WithKeys("y")),
+
+		HideDebug: key.NewBinding(key.WithKeys("D")),
+		HideInfo:  key.NewBinding(key.WithKeys("I")),
+
+		NextChange: key.NewBinding(key.WithKeys("]c")),
+		PrevChange: key.NewBinding(key.WithKeys("[c")),
+
+		SetBookmark:  key.NewBinding(key.WithKeys("m")),
+		GotoBookmark: key.NewBinding(key.WithKeys("'")),
+
+		NextSlide: key.NewBinding(key.WithKeys("right", "l", " ")),
+		PrevSlide: key.NewBinding(key.WithKeys("left", "h")),
+		Notes:     key.NewBinding(key.WithKeys("s")),
+
+		SearchConfirm: key.NewBinding(key.WithKeys("enter")),
+		SearchCancel:  key.NewBinding(key.WithKeys("esc", "ctrl+c")),
+		ToggleRegex:   key.NewBinding(key.WithKeys("alt+r")),
+		ToggleCase:    key.NewBinding(key.WithKeys("alt+c")),
+
+		PageDown:     vp.PageDown,
+		PageUp:       vp.PageUp,
+		HalfPageDown: vp.HalfPageDown,
+		HalfPageUp:   vp.HalfPageUp,
+		Down:         vp.Down,
+		Up:           vp.Up,
+		Left:         vp.Left,
+		Right:        vp.Right,
+	}
+}
+
+// keyActions maps the action names used in the config file to the bindings.
+func (k *keyMap) keyActions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":           &k.Quit,
+		"search":         &k.Search,
+		"next-match":     &k.NextMatch,
+		"prev-match":     &k.PrevMatch,
+		"next-tab":       &k.NextTab,
+		"prev-tab":       &k.PrevTab,
+		"toc":            &k.TOC,
+		"toc-jump":       &k.TOCJump,
+		"next-link":      &k.NextLink,
+		"prev-link":      &k.PrevLink,
+		"open-link":      &k.OpenLink,
+		"back":           &k.Back,
+		"forward":        &k.Forward,
+		"browse":         &k.Browse,
+		"command":        &k.Command,
+		"line-numbers":   &k.LineNumbers,
+		"front-matter":   &k.FrontMatter,
+		"wrap":           &k.Wrap,
+		"next-block":     &k.NextBlock,
+		"yank":           &k.Yank,
+		"hide-debug":     &k.HideDebug,
+		"hide-info":      &k.HideInfo,
+		"next-change":    &k.NextChange,
+		"prev-change":    &k.PrevChange,
+		"set-bookmark":   &k.SetBookmark,
+		"goto-bookmark":  &k.GotoBookmark,
+		"next-slide":     &k.NextSlide,
+		"prev-slide":     &k.PrevSlide,
+		"notes":          &k.Notes,
+		"search-confirm": &k.SearchConfirm,
+		"search-cancel":  &k.SearchCancel,
+		"toggle-regex":   &k.ToggleRegex,
+		"toggle-case":    &k.ToggleCase,
+		"page-down":      &k.PageDown,
+		"page-up":        &k.PageUp,
+		"half-page-down": &k.HalfPageDown,
+		"half-page-up":   &k.HalfPageUp,
+		"down":           &k.Down,
+		"up":             &k.Up,
+		"left":           &k.Left,
+		"right":          &k.Right,
+	}
+}
+
+// remap replaces the bindings of the given actions. An empty list of keys
+// disables the action.
+func (k *keyMap) remap(bindings map[string][]string) error {
+	actions := k.keyActions()
+	for action, ks := range bindings {
+		b, ok := actions[action]
+		if !ok {
+			return fmt.Errorf("unknown action %q, known actions are %s", action, strings.Join(actionNames(actions), ", "))
+		}
+		for _, s := range ks {
+			if strings.TrimSpace(s) == "" {
+				return fmt.Errorf("action %q: empty key", action)
+			}
+		}
+		*b = key.NewBinding(key.WithKeys(ks...))
+		if len(ks) == 0 {
+			b.SetEnabled(false)
+		}
+	}
+	return nil
+}
+
+func actionNames(actions map[string]*key.Binding) []string {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sequence checks the keys typed so far against a binding made of key
+// sequences like "]c", which arrive one key at a time. It reports whether
+// they complete one of its sequences, or are the start of one.
+func sequence(b key.Binding, typed string) (match, prefix bool) {
+	if !b.Enabled() {
+		return false, false
+	}
+	for _, k := range b.Keys() {
+		switch {
+		case k == typed:
+			match = true
+		case strings.HasPrefix(k, typed):
+			prefix = true
+		}
+	}
+	return match, prefix
+}
+
+// viewport returns the scrolling bindings for the viewports.
+func (k keyMap) viewport() viewport.KeyMap {
+	return viewport.KeyMap{
+		PageDown:     k.PageDown,
+		PageUp:       k.PageUp,
+		HalfPageDown: k.HalfPageDown,
+		HalfPageUp:   k.HalfPageUp,
+		Down:         k.Down,
+		Up:           k.Up,
+		Left:         k.Left,
+		Right:        k.Right,
+	}
+}
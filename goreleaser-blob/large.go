@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// largeFileSize is the size from which local files are not rendered as
+// Markdown, but paged as plain text straight from the disk.
+const largeFileSize = 16 << 20
+
+const (
+	// checkpointLines is how many lines apart the offsets in the index are.
+	checkpointLines = 1024
+
+	// indexChunkSize is how much of the file is indexed in one go. Keys are
+	// handled in between.
+	indexChunkSize = 32 << 20
+
+	// maxLineLength cuts very long lines, like those of minified files, so
+	// that a screen full of them still fits into memory.
+	maxLineLength = 64 << 10
+)
+
+// largeFile pages through a file too large to render or to keep in memory.
+// Only the lines on screen and a screen above and below them are read. The
+// index of where the lines start only has every checkpointLines-th line and
+// is built in the background, so the first screen shows up at once.
+type largeFile struct {
+	name string
+	f    *os.File
+	size int64
+
+	// checkpoints[i] is where line i*checkpointLines starts. lines is the
+	// number of lines in the first indexed bytes.
+	checkpoints []int64
+	lines       int
+	indexed     int64
+	indexErr    error
+
+	top, xOffset  int
+	width, height int
+	lineNumbers   bool
+
+	// log colors the levels of the lines on screen. Lines can't be hidden,
+	// that would need an index of the levels as well.
+	log bool
+
+	// window are the lines read from windowStart on.
+	window      []string
+	windowStart int
+	readErr     error
+
+	search search
+	// searchID tells the search in flight from those it replaced, which
+	// stop as soon as they notice.
+	searchID  atomic.Int64
+	searching bool
+	found     *largeMatch
+
+	// pending is the line to scroll to as soon as it is indexed, or -1,
+	// with pendingAbove lines above it on screen.
+	pending      int
+	pendingAbove int
+}
+
+// largeMatch is a line the search found, with the columns of the first
+// match in it.
+type largeMatch struct {
+	line, start, end int
+}
+
+// indexedMsg carries the line index of the bytes from from to to.
+type indexedMsg struct {
+	from, to    int64
+	lines       int
+	checkpoints []int64
+	err         error
+}
+
+// largeSearchMsg is the result of a search through the whole file.
+type largeSearchMsg struct {
+	id      int64
+	match   *largeMatch
+	wrapped bool
+	err     error
+}
+
+// isLargeFile reports whether path is a local file too large to render.
+func isLargeFile(path string) bool {
+	if path == stdinName || isBlobURL(path) {
+		return false
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Size() >= largeFileSize
+}
+
+func openLargeFile(path string, s search) (*largeFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &largeFile{
+		name:        filepath.Base(path),
+		f:           f,
+		size:        fi.Size(),
+		checkpoints: []int64{0},
+		search:      s,
+		pending:     -1,
+	}, nil
+}
+
+// close closes the file, once we are done paging it.
+func (l *largeFile) close() error {
+	return l.f.Close()
+}
+
+func (l *largeFile) indexDone() bool {
+	return l.indexed >= l.size || l.indexErr != nil
+}
+
+// indexNext indexes the next chunk of the file.
+func (l *largeFile) indexNext() tea.Cmd {
+	if l.indexDone() {
+		return nil
+	}
+	from, lines, size := l.indexed, l.lines, l.size
+	return func() tea.Msg {
+		buf := make([]byte, min(indexChunkSize, size-from))
+		n, err := l.f.ReadAt(buf, from)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return indexedMsg{from: from, err: err}
+		}
+		buf = buf[:n]
+		msg := indexedMsg{from: from, to: from + int64(n), lines: lines}
+		for i := 0; ; {
+			j := bytes.IndexByte(buf[i:], '\n')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+			msg.lines++
+			if msg.lines%checkpointLines == 0 {
+				msg.checkpoints = append(msg.checkpoints, from+int64(i))
+			}
+		}
+		// The last line does not need a line break.
+		if msg.to == size && n > 0 && buf[n-1] != '\n' {
+			msg.lines++
+		}
+		return msg
+	}
+}
+
+// indexAll builds the whole index right away, for --script.
+func (l *largeFile) indexAll() {
+	for cmd := l.indexNext(); cmd != nil; {
+		cmd = l.applyIndex(cmd().(indexedMsg))
+	}
+}
+
+// applyIndex adds an indexed chunk and starts on the next one.
+func (l *largeFile) applyIndex(msg indexedMsg) tea.Cmd {
+	if msg.from != l.indexed {
+		return nil
+	}
+	if msg.err != nil {
+		l.indexErr = msg.err
+		return nil
+	}
+	if msg.to == msg.from {
+		// The file was truncated while we were reading it.
+		l.size = l.indexed
+	}
+	l.indexed, l.lines = msg.to, msg.lines
+	l.checkpoints = append(l.checkpoints, msg.checkpoints...)
+	if l.pending >= 0 && l.pending < l.lines {
+		l.scrollTo(l.pending - l.pendingAbove)
+		l.pending = -1
+	}
+	l.load()
+	return l.indexNext()
+}
+
+// readLine reads a line without its line break, cut to maxLineLength.
+func readLine(r *bufio.Reader) (string, error) {
+	var b []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if room := maxLineLength - len(b); room > 0 {
+			b = append(b, chunk[:min(len(chunk), room)]...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && len(b) > 0 {
+			err = nil
+		}
+		return lineText(b), err
+	}
+}
+
+// lineText turns the bytes of a line into the text we show, without the line
+// break and cut to maxLineLength.
+func lineText(b []byte) string {
+	b = bytes.TrimRight(b[:min(len(b), maxLineLength)], "\r\n")
+	return strings.ToValidUTF8(string(b), "�")
+}
+
+// offset returns where the given line starts. The line has to be indexed.
+func (l *largeFile) offset(line int) (int64, error) {
+	cp := min(line/checkpointLines, len(l.checkpoints)-1)
+	off := l.checkpoints[cp]
+	r := bufio.NewReaderSize(io.NewSectionReader(l.f, off, l.size-off), 64<<10)
+	for i := cp * checkpointLines; i < line; {
+		chunk, err := r.ReadSlice('\n')
+		off += int64(len(chunk))
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err != nil:
+			return off, err
+		}
+		i++
+	}
+	return off, nil
+}
+
+// reader returns a reader positioned at the start of the given line.
+func (l *largeFile) reader(line int) (*bufio.Reader, error) {
+	off, err := l.offset(line)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReaderSize(io.NewSectionReader(l.f, off, l.size-off), 64<<10), nil
+}
+
+// readLines reads up to n lines, starting with the given one.
+func (l *largeFile) readLines(from, n int) ([]string, error) {
+	r, err := l.reader(from)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for i := from; i < from+n && i < l.lines; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// load reads the lines on screen, unless they were read already.
+func (l *largeFile) load() {
+	end := min(l.top+l.height, l.lines)
+	if l.window != nil && l.top >= l.windowStart && end <= l.windowStart+len(l.window) {
+		return
+	}
+	l.windowStart = max(0, l.top-l.height)
+	l.window, l.readErr = l.readLines(l.windowStart, 3*l.height)
+}
+
+func (l *largeFile) setSize(width, height int) {
+	l.width, l.height = width, height
+	l.window = nil
+	l.scrollTo(l.top)
+}
+
+// scrollTo scrolls to the given line, as far as the file is indexed.
+func (l *largeFile) scrollTo(top int) {
+	l.top = max(0, min(top, l.lines-l.height))
+	l.load()
+}
+
+func (l *largeFile) scrollColumns(delta int) {
+	l.xOffset = max(0, min(l.xOffset+delta, maxLineLength))
+}
+
+// show scrolls to a line the search found, once it is indexed.
+func (l *largeFile) show(mt *largeMatch) {
+	if mt.line >= l.lines {
+		l.pending, l.pendingAbove = mt.line, l.height/2
+		return
+	}
+	if mt.line < l.top || mt.line >= l.top+l.height {
+		l.scrollTo(mt.line - l.height/2)
+	}
+	textWidth := l.width - l.gutterWidth()
+	switch {
+	case mt.start < l.xOffset:
+		l.xOffset = mt.start
+	case mt.end > l.xOffset+textWidth:
+		l.xOffset = max(0, min(mt.start, mt.end-textWidth))
+	}
+}
+
+func (l *largeFile) gutterWidth() int {
+	if !l.lineNumbers {
+		return 0
+	}
+	return gutterWidth(l.lines)
+}
+
+// find searches the whole file for the query, from the given line on to the
+// end and then from the start, or backwards. It can take a while, so it runs
+// as a command, and starting another search, or none, abandons it.
+//
+// The file is read a chunk at a time, but like on screen, the query is
+// matched against every line on its own, without its ANSI styling.
+func (l *largeFile) find(from int, backwards bool) tea.Cmd {
+	id := l.searchID.Add(1)
+	l.found = nil
+	l.searching = false
+	re, err := l.search.compile()
+	l.search.err = err
+	if err != nil || l.search.query == "" {
+		return nil
+	}
+	from = max(0, min(from, l.lines))
+	off, err := l.offset(from)
+	if err != nil && !errors.Is(err, io.EOF) {
+		l.search.err = err
+		return nil
+	}
+	l.searching = true
+	size := l.size
+	return func() tea.Msg {
+		// Look from the line to the end first, then from the start to the
+		// line, or the other way around when going backwards.
+		rest := func() (*largeMatch, error) { return l.scan(id, off, size, from, re, !backwards) }
+		head := func() (*largeMatch, error) { return l.scan(id, 0, off, 0, re, !backwards) }
+		first, second := rest, head
+		if backwards {
+			first, second = head, rest
+		}
+		mt, err := first()
+		if mt != nil || err != nil {
+			return largeSearchMsg{id: id, match: mt, err: err}
+		}
+		mt, err = second()
+		return largeSearchMsg{id: id, match: mt, wrapped: mt != nil, err: err}
+	}
+}
+
+// searchChunkSize is how much of the file is searched at once.
+const searchChunkSize = 4 << 20
+
+// errSearchAbandoned ends a search that another one replaced.
+var errSearchAbandoned = errors.New("search abandoned")
+
+// scan looks for re in the lines from from to to, where from is the start of
+// the given line. It returns the first match, or the last one if first is
+// false. It gives up once id is no longer the current search.
+func (l *largeFile) scan(id, from, to int64, line int, re *regexp.Regexp, first bool) (*largeMatch, error) {
+	var (
+		found *largeMatch
+		// cut is set while in a line longer than a chunk, the rest of
+		// which is beyond maxLineLength.
+		cut bool
+	)
+	buf := make([]byte, searchChunkSize)
+	for from < to {
+		if l.searchID.Load() != id {
+			return nil, errSearchAbandoned
+		}
+		n, err := l.f.ReadAt(buf[:min(int64(len(buf)), to-from)], from)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		chunk := buf[:n]
+		// Only search whole lines, unless a line is longer than the chunk.
+		if end := bytes.LastIndexByte(chunk, '\n'); end >= 0 && from+int64(n) < to {
+			chunk = chunk[:end+1]
+		}
+		from += int64(len(chunk))
+		for len(chunk) > 0 {
+			raw := chunk
+			if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+				raw, chunk = chunk[:i+1], chunk[i+1:]
+			} else {
+				chunk = nil
+			}
+			skip := cut
+			cut = raw[len(raw)-1] != '\n'
+			if skip {
+				if !cut {
+					line++
+				}
+				continue
+			}
+			if mt := l.matchLine(lineText(raw), line, re); mt != nil {
+				if first {
+					return mt, nil
+				}
+				found = mt
+			}
+			if !cut {
+				line++
+			}
+		}
+	}
+	return found, nil
+}
+
+// matchLine matches re against the line the way it is shown, and returns the
+// columns of the first match.
+func (l *largeFile) matchLine(text string, line int, re *regexp.Regexp) *largeMatch {
+	plain := ansi.Strip(l.shown(text))
+	for _, loc := range re.FindAllStringIndex(plain, -1) {
+		if loc[0] < loc[1] {
+			return &largeMatch{line: line, start: ansi.StringWidth(plain[:loc[0]]), end: ansi.StringWidth(plain[:loc[1]])}
+		}
+	}
+	return nil
+}
+
+// shown is the line the way it is put on screen: with its tabs expanded, or
+// sanitized in --log mode.
+func (l *largeFile) shown(line string) string {
+	if l.log {
+		return sanitizeLog(line)
+	}
+	return expandTabs(line)
+}
+
+// expandTabs replaces tabs with spaces up to the next multiple of 8 columns.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col += ansi.StringWidth(string(r))
+	}
+	return b.String()
+}
+
+// view renders the lines on screen.
+func (l *largeFile) view() string {
+	var lines []string
+	if l.top >= l.windowStart && l.top < l.windowStart+len(l.window) {
+		lines = l.window[l.top-l.windowStart : min(l.top-l.windowStart+l.height, len(l.window))]
+	}
+	plain := make([]string, len(lines))
+	for i, line := range lines {
+		plain[i] = l.shown(line)
+	}
+	s := l.search
+	s.find(plain)
+	s.current = -1
+	for i, mt := range s.matches {
+		if l.found != nil && l.top+mt.line == l.found.line && mt.start == l.found.start {
+			s.current = i
+		}
+	}
+	plain = s.highlight(plain)
+	if l.log {
+		for i, line := range plain {
+			lvl, start, end, ok := detectLevel(ansi.Strip(line))
+			if style, colored := lvl.style(); ok && colored {
+				plain[i] = lipgloss.StyleRanges(line, lipgloss.NewRange(start, end, style))
+			}
+		}
+	}
+
+	gutter := l.gutterWidth()
+	out := make([]string, l.height)
+	for i := range out {
+		if i >= len(plain) {
+			continue
+		}
+		line := ansi.Cut(plain[i], l.xOffset, l.xOffset+l.width-gutter)
+		if gutter > 0 {
+			line = lineNumberStyle.Render(fmt.Sprintf("%*d", gutter-1, l.top+i+1)) + " " + line
+		}
+		out[i] = line
+	}
+	return strings.Join(out, "\n")
+}
+
+// status describes where we are, e.g. "indexing 40% 1-40/123456 0%".
+func (l *largeFile) status(fields []string) string {
+	var parts []string
+	switch {
+	case l.search.query == "":
+	case l.search.err != nil:
+		parts = append(parts, "bad pattern")
+	case l.searching:
+		parts = append(parts, "searching…")
+	case l.found == nil:
+		parts = append(parts, "no matches")
+	default:
+		parts = append(parts, fmt.Sprintf("match in line %d", l.found.line+1))
+	}
+	switch {
+	case l.indexErr != nil:
+		parts = append(parts, "could not index: "+l.indexErr.Error())
+	case l.readErr != nil:
+		parts = append(parts, "could not read: "+l.readErr.Error())
+	case !l.indexDone():
+		parts = append(parts, fmt.Sprintf("indexing %d%%", l.indexed*100/l.size))
+	}
+	if l.xOffset > 0 {
+		parts = append(parts, fmt.Sprintf("col %d", l.xOffset+1))
+	}
+	for _, f := range fields {
+		switch f {
+		case footerLines:
+			parts = append(parts, fmt.Sprintf("%d-%d/%d", min(l.top+1, l.lines), min(l.top+l.height, l.lines), l.lines))
+		case footerPercent:
+			pct := 100
+			if l.lines > l.height {
+				pct = l.top * 100 / (l.lines - l.height)
+			}
+			parts = append(parts, fmt.Sprintf("%d%%", pct))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// largeFooter is the footer when paging a large file.
+func (m model) largeFooter() string {
+	l := m.large
+	status := l.status(m.footer)
+	switch {
+	case m.err != nil:
+		status = m.err.Error() + " " + status
+	case m.msg != "":
+		status = m.msg + " " + status
+	}
+	var prompt string
+	switch {
+	case l.search.prompting:
+		prompt = l.search.input.View() + " " + l.search.modes() + " "
+	case m.command.prompting:
+		prompt = m.command.input.View() + " "
+	}
+	return m.statusLine(prompt, status)
+}
+
+// run executes the commands of the ":" prompt that make sense without a
+// rendered document, going to a line or a percentage.
+func (l *largeFile) run(cmd string) (string, error) {
+	cmd = strings.TrimSpace(cmd)
+	switch {
+	case cmd == "":
+		return "", nil
+	case strings.HasSuffix(cmd, "%"):
+		pct, err := strconv.Atoi(strings.TrimSuffix(cmd, "%"))
+		if err != nil || pct < 0 || pct > 100 {
+			return "", fmt.Errorf("bad percentage: %s", cmd)
+		}
+		l.scrollTo((l.lines - l.height) * pct / 100)
+		return "", nil
+	}
+	n, err := strconv.Atoi(cmd)
+	if err != nil {
+		return "", fmt.Errorf("unknown command: %s, large files only go to :line and :percent%%", cmd)
+	}
+	if n < 1 {
+		return "", fmt.Errorf("bad line: %d", n)
+	}
+	if n > l.lines && !l.indexDone() {
+		// Not indexed yet, we get there once it is.
+		l.pending, l.pendingAbove = n-1, 0
+		l.scrollTo(l.lines)
+		return fmt.Sprintf("going to line %d once it is indexed", n), nil
+	}
+	l.pending = -1
+	l.scrollTo(n - 1)
+	return "", nil
+}
+
+// updateLarge handles messages when paging a large file.
+func (m model) updateLarge(msg tea.Msg) (tea.Model, tea.Cmd) {
+	l := m.large
+	switch msg := msg.(type) {
+	case indexedMsg:
+		return m, l.applyIndex(msg)
+
+	case largeSearchMsg:
+		if msg.id != l.searchID.Load() {
+			return m, nil
+		}
+		l.searching = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("search: %w", msg.err)
+			return m, nil
+		}
+		l.found = msg.match
+		if msg.match != nil {
+			l.show(msg.match)
+			if msg.wrapped {
+				m.msg = "search wrapped"
+			}
+		}
+		return m, nil
+
+	case tea.MouseMsg:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+		switch {
+		case msg.Button == tea.MouseButtonWheelLeft, msg.Shift && msg.Button == tea.MouseButtonWheelUp:
+			l.scrollColumns(-horizontalStep)
+		case msg.Button == tea.MouseButtonWheelRight, msg.Shift && msg.Button == tea.MouseButtonWheelDown:
+			l.scrollColumns(horizontalStep)
+		case msg.Button == tea.MouseButtonWheelUp:
+			l.scrollTo(l.top - 3)
+		case msg.Button == tea.MouseButtonWheelDown:
+			l.scrollTo(l.top + 3)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		m.err = nil
+		m.msg = ""
+		if l.search.prompting {
+			return m, l.updateSearch(msg)
+		}
+		if m.command.prompting {
+			return m.updateCommand(msg)
+		}
+		switch {
+		case key.Matches(msg, keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, keys.Search):
+			l.search.prompting = true
+			l.search.input.SetValue("")
+			return m, l.search.input.Focus()
+		case key.Matches(msg, keys.Command):
+			return m, m.startCommand()
+		case key.Matches(msg, keys.NextMatch):
+			from := l.top
+			if l.found != nil {
+				from = l.found.line + 1
+			}
+			return m, l.find(from, false)
+		case key.Matches(msg, keys.PrevMatch):
+			from := l.top
+			if l.found != nil {
+				from = l.found.line
+			}
+			return m, l.find(from, true)
+		case key.Matches(msg, keys.LineNumbers):
+			l.lineNumbers = !l.lineNumbers
+		case key.Matches(msg, keys.Down):
+			l.scrollTo(l.top + 1)
+		case key.Matches(msg, keys.Up):
+			l.scrollTo(l.top - 1)
+		case key.Matches(msg, keys.PageDown):
+			l.scrollTo(l.top + l.height)
+		case key.Matches(msg, keys.PageUp):
+			l.scrollTo(l.top - l.height)
+		case key.Matches(msg, keys.HalfPageDown):
+			l.scrollTo(l.top + l.height/2)
+		case key.Matches(msg, keys.HalfPageUp):
+			l.scrollTo(l.top - l.height/2)
+		case key.Matches(msg, keys.Left):
+			l.scrollColumns(-horizontalStep)
+		case key.Matches(msg, keys.Right):
+			l.scrollColumns(horizontalStep)
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.command.prompting {
+		m.command.input, cmd = m.command.input.Update(msg)
+	}
+	return m, cmd
+}
+
+// updateSearch handles key presses while the search prompt is open. The
+// lines on screen are highlighted as you type, the whole file is only
+// searched once the query is confirmed.
+func (l *largeFile) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, keys.SearchConfirm):
+		l.search.prompting = false
+		l.search.input.Blur()
+		return l.find(l.top, false)
+	case key.Matches(msg, keys.SearchCancel):
+		l.search.prompting = false
+		l.search.input.Blur()
+		l.search.query = ""
+		l.found = nil
+		l.searching = false
+		l.searchID.Add(1)
+		return nil
+	case key.Matches(msg, keys.ToggleRegex):
+		l.search.regex = !l.search.regex
+	case key.Matches(msg, keys.ToggleCase):
+		l.search.ignoreCase = !l.search.ignoreCase
+	default:
+		var cmd tea.Cmd
+		l.search.input, cmd = l.search.input.Update(msg)
+		l.search.query = l.search.input.Value()
+		return cmd
+	}
+	return nil
+}
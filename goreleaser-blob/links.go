@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+var selectedLinkStyle = lipgloss.NewStyle().Reverse(true).Underline(true)
+
+// position is a cell position in the rendered document.
+type position struct {
+	line, col int
+}
+
+// link is a Markdown link together with the place glamour rendered its text
+// at. Links we could not find in the rendered output are not selectable.
+type link struct {
+	text       string
+	dest       string
+	start, end position
+}
+
+// parseLinks extracts the text and destination of all links from the
+// Markdown source in document order.
+func parseLinks(source string) []link {
+	src := []byte(source)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	var links []link
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if l, ok := n.(*ast.Link); ok && entering {
+			t := strings.Join(strings.Fields(nodeText(l, src)), " ")
+			if t != "" {
+				links = append(links, link{text: t, dest: string(l.Destination)})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// plainText is the rendered document without any styling, joined into a
+// single string so we can match text that was wrapped over several lines.
+type plainText struct {
+	text   string
+	starts []int
+	lines  []string
+}
+
+func newPlainText(lines []string) plainText {
+	p := plainText{lines: make([]string, len(lines)), starts: make([]int, len(lines))}
+	var b strings.Builder
+	for i, l := range lines {
+		p.lines[i] = ansi.Strip(l)
+		p.starts[i] = b.Len()
+		b.WriteString(p.lines[i])
+		b.WriteByte('\n')
+	}
+	p.text = b.String()
+	return p
+}
+
+// position maps a byte offset into the joined text back to a line and cell
+// column of the rendered document.
+func (p plainText) position(off int) position {
+	line := max(0, sort.SearchInts(p.starts, off+1)-1)
+	return position{line: line, col: ansi.StringWidth(p.lines[line][:off-p.starts[line]])}
+}
+
+// fieldsPattern turns s into a regular expression that matches it with any
+// whitespace, including line breaks and margins, between its words.
+func fieldsPattern(s string) string {
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		fields[i] = regexp.QuoteMeta(f)
+	}
+	return strings.Join(fields, `\s+`)
+}
+
+// locateLinks finds the rendered text of every link. Glamour renders a link
+// as its text followed by its destination, which we match together so a
+// link text that also appears in the prose does not throw us off.
+func locateLinks(links []link, lines []string) []link {
+	p := newPlainText(lines)
+	var found []link
+	from := 0
+	for _, l := range links {
+		expr := "(" + fieldsPattern(l.text) + ")"
+		if !strings.HasPrefix(l.dest, "#") {
+			expr += `\s+` + fieldsPattern(renderedDest(l.dest))
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			continue
+		}
+		loc := re.FindStringSubmatchIndex(p.text[from:])
+		if loc == nil {
+			continue
+		}
+		l.start = p.position(from + loc[2])
+		l.end = p.position(from + loc[3])
+		found = append(found, l)
+		from += loc[1]
+	}
+	return found
+}
+
+// renderedDest returns the destination of a link the way glamour prints it,
+// which resolves relative links against an empty base URL.
+func renderedDest(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.IsAbs() {
+		return dest
+	}
+	u.Path = strings.TrimPrefix(u.Path, "/")
+	return new(url.URL).ResolveReference(u).String()
+}
+
+// highlightLink returns a copy of lines with the selected link highlighted.
+func (d *document) highlightLink(lines []string) []string {
+	if d.selectedLink < 0 || d.selectedLink >= len(d.links) {
+		return lines
+	}
+	return styleLink(lines, d.links[d.selectedLink], selectedLinkStyle)
+}
+
+// styleLink returns a copy of lines with the text of the link styled.
+func styleLink(lines []string, l link, style lipgloss.Style) []string {
+	out := make([]string, len(lines))
+	copy(out, lines)
+	for i := l.start.line; i <= l.end.line && i < len(out); i++ {
+		start, end := 0, ansi.StringWidth(out[i])
+		if i == l.start.line {
+			start = l.start.col
+		}
+		if i == l.end.line {
+			end = l.end.col
+		}
+		// Don't highlight the document margin of wrapped lines.
+		if i != l.start.line {
+			plain := ansi.Strip(out[i])
+			start = ansi.StringWidth(plain[:len(plain)-len(strings.TrimLeft(plain, " "))])
+		}
+		out[i] = lipgloss.StyleRanges(out[i], lipgloss.NewRange(start, end, style))
+	}
+	return out
+}
+
+// selectLink moves the link selection by delta. Without a selection, we
+// start at the first link on screen.
+func (d *document) selectLink(delta int) {
+	if len(d.links) == 0 {
+		return
+	}
+	switch {
+	case d.selectedLink < 0 && delta > 0:
+		d.selectedLink = 0
+		for i, l := range d.links {
+			if l.start.line >= d.viewport.YOffset {
+				d.selectedLink = i
+				break
+			}
+		}
+	case d.selectedLink < 0:
+		d.selectedLink = len(d.links) - 1
+		for i := len(d.links) - 1; i >= 0; i-- {
+			if d.links[i].start.line < d.viewport.YOffset+d.viewport.Height {
+				d.selectedLink = i
+				break
+			}
+		}
+	default:
+		d.selectedLink = (d.selectedLink + delta + len(d.links)) % len(d.links)
+	}
+	d.updateContent()
+
+	l := d.links[d.selectedLink]
+	if l.start.line < d.viewport.YOffset || l.start.line >= d.viewport.YOffset+d.viewport.Height {
+		d.viewport.SetYOffset(max(0, l.start.line-d.viewport.Height/2))
+	}
+	end := l.end.col
+	if l.end.line != l.start.line {
+		end = l.start.col + 1
+	}
+	d.showColumns(l.start.col, end)
+}
+
+// slug returns the GitHub style anchor of a heading.
+func slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// jumpToAnchor scrolls to the heading with the given anchor. Duplicate
+// headings get a numbered suffix, the same way GitHub does it.
+func (d *document) jumpToAnchor(anchor string) error {
+	seen := map[string]int{}
+	for i, h := range d.headings {
+		s := slug(h.text)
+		if n := seen[s]; n > 0 {
+			seen[s]++
+			s = fmt.Sprintf("%s-%d", s, n)
+		} else {
+			seen[s] = 1
+		}
+		if s == strings.ToLower(anchor) {
+			d.jumpToHeading(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("no heading for #%s", anchor)
+}
+
+// isMarkdown reports whether the path looks like a Markdown file.
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// resolveLink works out which local file a link points to, relative to the
+// document it is in. An empty path means the link points into the document
+// itself.
+func (d *document) resolveLink(dest string) (path, anchor string, err error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", "", fmt.Errorf("bad link %s: %w", dest, err)
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return "", "", fmt.Errorf("cannot open %s: not a local file", dest)
+	}
+	if u.Path == "" {
+		return "", u.Fragment, nil
+	}
+	if d.path == "" {
+		return "", "", fmt.Errorf("cannot open %s: no directory to resolve it against", dest)
+	}
+	path = filepath.FromSlash(u.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(d.path), path)
+	}
+	if !isMarkdown(path) {
+		return "", "", fmt.Errorf("cannot open %s: not a Markdown file", dest)
+	}
+	return path, u.Fragment, nil
+}
+
+// openLink opens the selected link: Markdown files in the pager, and web
+// pages and everything else with a scheme with the system opener.
+func (m *model) openLink() (tea.Cmd, error) {
+	d := m.doc()
+	if d.selectedLink < 0 || d.selectedLink >= len(d.links) {
+		return nil, nil
+	}
+	if dest := d.links[d.selectedLink].dest; hasScheme(dest) {
+		return openURL(dest), nil
+	}
+	return nil, m.followLink()
+}
+
+// hasScheme reports whether the link has a scheme, like https: or mailto:.
+func hasScheme(dest string) bool {
+	u, err := url.Parse(dest)
+	return err == nil && u.Scheme != ""
+}
+
+// followLink opens the selected link in the active tab.
+func (m *model) followLink() error {
+	t := m.tab()
+	d := t.doc
+	if d.selectedLink < 0 || d.selectedLink >= len(d.links) {
+		return nil
+	}
+	dest := d.links[d.selectedLink].dest
+	path, anchor, err := d.resolveLink(dest)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return d.jumpToAnchor(anchor)
+	}
+
+	nd, err := loadDocument(path, newSearch(d.search.regex, d.search.ignoreCase))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot open %s: no such file", dest)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", dest, err)
+	}
+	m.opened(nd)
+	t.visit(nd)
+	m.layout()
+	if m.follow != nil {
+		if err := m.follow.add(nd); err != nil {
+			return err
+		}
+	}
+	if anchor != "" {
+		return nd.jumpToAnchor(anchor)
+	}
+	return nil
+}
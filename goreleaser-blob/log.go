@@ -0,0 +1,225 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	logDebugStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	logInfoStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
+	logWarnStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	logErrorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+)
+
+// logLevel is the severity of a log line.
+type logLevel int
+
+const (
+	levelNone logLevel = iota
+	levelTrace
+	levelDebug
+	levelInfo
+	levelWarn
+	levelError
+	levelFatal
+)
+
+var levelNames = map[string]logLevel{
+	"trace":    levelTrace,
+	"debug":    levelDebug,
+	"dbg":      levelDebug,
+	"info":     levelInfo,
+	"notice":   levelInfo,
+	"warn":     levelWarn,
+	"warning":  levelWarn,
+	"error":    levelError,
+	"err":      levelError,
+	"fatal":    levelFatal,
+	"panic":    levelFatal,
+	"crit":     levelFatal,
+	"critical": levelFatal,
+}
+
+func (l logLevel) style() (lipgloss.Style, bool) {
+	switch l {
+	case levelTrace, levelDebug:
+		return logDebugStyle, true
+	case levelInfo:
+		return logInfoStyle, true
+	case levelWarn:
+		return logWarnStyle, true
+	case levelError, levelFatal:
+		return logErrorStyle, true
+	}
+	return lipgloss.Style{}, false
+}
+
+// The ways we find the level of a line: a "level" field in JSON, a level=
+// pair in logfmt, or else an upper case level or one in brackets, like
+// "WARN" or "[info]", in plain text.
+var (
+	jsonLevel   = regexp.MustCompile(`"(?:level|lvl|severity|log\.level)"\s*:\s*"(\w+)"`)
+	logfmtLevel = regexp.MustCompile(`(?:^|\s)(?:level|lvl|severity)="?(\w+)"?(?:\s|$)`)
+	plainLevel  = regexp.MustCompile(`\b(TRACE|DEBUG|DBG|INFO|NOTICE|WARN(?:ING)?|ERROR|ERR|FATAL|PANIC|CRIT(?:ICAL)?)\b|\[(?i:(trace|debug|info|warn(?:ing)?|error|fatal))\]`)
+)
+
+// logLine is a line of a log, with its level and the columns the level is
+// written in, if it is written in the line at all.
+type logLine struct {
+	text       string
+	level      logLevel
+	start, end int
+}
+
+// parseLog splits a log into lines and finds their levels. Lines without a
+// level of their own, like stack traces, belong to the line before them.
+func parseLog(content string) []logLine {
+	content = strings.TrimSuffix(content, "\n")
+	var (
+		lines []logLine
+		level = levelNone
+	)
+	for _, raw := range strings.Split(content, "\n") {
+		l := logLine{text: sanitizeLog(raw), level: level}
+		if lvl, start, end, ok := detectLevel(ansi.Strip(l.text)); ok {
+			l.level, l.start, l.end = lvl, start, end
+			level = lvl
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// detectLevel finds the level of a line without ANSI sequences, returning
+// the columns it is written in.
+func detectLevel(plain string) (logLevel, int, int, bool) {
+	re := plainLevel
+	switch trimmed := strings.TrimSpace(plain); {
+	case strings.HasPrefix(trimmed, "{"):
+		re = jsonLevel
+	case logfmtLevel.MatchString(plain):
+		re = logfmtLevel
+	}
+	for _, loc := range re.FindAllStringSubmatchIndex(plain, -1) {
+		for g := 2; g+1 < len(loc); g += 2 {
+			if loc[g] < 0 {
+				continue
+			}
+			if lvl, ok := levelNames[strings.ToLower(plain[loc[g]:loc[g+1]])]; ok {
+				return lvl, ansi.StringWidth(plain[:loc[g]]), ansi.StringWidth(plain[:loc[g+1]]), true
+			}
+		}
+	}
+	return levelNone, 0, 0, false
+}
+
+var (
+	csiSequence = regexp.MustCompile(`\x1b\[[0-9;:?<=>]*[ -/]*[@-~]`)
+	oscSequence = regexp.MustCompile(`\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+)
+
+// sanitizeLog keeps the colors of a log line but drops everything else that
+// would mess up the screen: cursor movement, titles, control characters and
+// what a progress bar overwrote with a carriage return.
+func sanitizeLog(s string) string {
+	s = strings.TrimSuffix(s, "\r")
+	if i := strings.LastIndex(s, "\r"); i >= 0 {
+		s = s[i+1:]
+	}
+	s = oscSequence.ReplaceAllString(s, "")
+	s = csiSequence.ReplaceAllStringFunc(s, func(seq string) string {
+		if strings.HasSuffix(seq, "m") {
+			return seq
+		}
+		return ""
+	})
+	s = strings.Map(func(r rune) rune {
+		if r == '\x1b' || r == '\t' || !unicode.IsControl(r) {
+			return r
+		}
+		return -1
+	}, s)
+	s = expandTabs(s)
+	if strings.Contains(s, "\x1b") {
+		// Don't let colors that are never reset bleed into the next line.
+		s += ansi.ResetStyle
+	}
+	return s
+}
+
+// hidden reports whether lines of the level are filtered out.
+func (d *document) hidden(level logLevel) bool {
+	switch level {
+	case levelTrace, levelDebug:
+		return d.hideDebug
+	case levelInfo:
+		return d.hideInfo
+	}
+	return false
+}
+
+// renderLog renders the log lines that are not filtered out, with their
+// levels colored, wrapped to the width unless wrapping is off. It remembers
+// which line of the log each rendered line belongs to in logSource.
+func (d *document) renderLog(width int) string {
+	d.logSource = d.logSource[:0]
+	var out []string
+	for i, l := range d.logLines {
+		if d.hidden(l.level) {
+			continue
+		}
+		text := l.text
+		if style, ok := l.level.style(); ok && l.end > l.start {
+			text = lipgloss.StyleRanges(text, lipgloss.NewRange(l.start, l.end, style))
+		}
+		parts := []string{text}
+		if d.wrap && width > 0 {
+			parts = strings.Split(ansi.Wrap(text, width, ""), "\n")
+		}
+		for _, p := range parts {
+			out = append(out, p)
+			d.logSource = append(d.logSource, i)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// logAnchor returns the log line at the top of the screen.
+func (d *document) logAnchor() int {
+	if d.viewport.YOffset < len(d.logSource) {
+		return d.logSource[d.viewport.YOffset]
+	}
+	return len(d.logLines)
+}
+
+// scrollToLog scrolls to the first rendered line of the given log line, or
+// of the next one that is shown if it is filtered out.
+func (d *document) scrollToLog(line int) {
+	for i, src := range d.logSource {
+		if src >= line {
+			d.viewport.SetYOffset(i)
+			return
+		}
+	}
+	d.viewport.GotoBottom()
+}
+
+// logStatus lists the levels that are filtered out, e.g. "hiding debug".
+func (d *document) logStatus() string {
+	var hidden []string
+	if d.hideDebug {
+		hidden = append(hidden, "debug")
+	}
+	if d.hideInfo {
+		hidden = append(hidden, "info")
+	}
+	if len(hidden) == 0 {
+		return ""
+	}
+	return "hiding " + strings.Join(hidden, ", ")
+}
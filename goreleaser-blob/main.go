@@ -4,15 +4,18 @@ package main
 // component library.
 
 import (
+	"errors"
 	"fmt"
 	"gopkg.in/alecthomas/kingpin.v2"
-	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
 )
 
 // You generally won't need this unless you're processing stuff with
@@ -35,16 +38,102 @@ var (
 		b.Left = "┤"
 		return titleStyle.Copy().BorderStyle(b)
 	}()
+
+	tabStyle       = lipgloss.NewStyle().Padding(0, 1)
+	activeTabStyle = tabStyle.Copy().Bold(true).Reverse(true)
 )
 
 type model struct {
-	content  string
-	ready    bool
-	viewport viewport.Model
+	tabs   []*tab
+	active int
+	title  string
+	style  string
+	ready  bool
+	width  int
+	height int
+	toc    toc
+
+	command     command
+	lineNumbers bool
+	rawMeta     bool
+	wrap        bool
+
+	// footer are the fields shown in the footer, see config.Footer.
+	footer []string
+
+	// store keeps positions and bookmarks between runs, if we have a
+	// place to keep them.
+	store *store
+
+	// bookmark is the pending bookmark action, waiting for the name of the
+	// bookmark.
+	bookmark *key.Binding
+
+	// follow is only set in --follow mode.
+	follow *follower
+
+	// diff is only set in --diff mode.
+	diff *diffView
+
+	// large is only set when paging a file too large to render.
+	large *largeFile
+
+	// browser is only set when we were pointed at a directory.
+	browser  *browser
+	browsing bool
+
+	// slides shows every document as a deck of slides.
+	slides bool
+
+	// log shows every document as a log, hiding the lines of the levels
+	// that were toggled off.
+	log       bool
+	hideDebug bool
+	hideInfo  bool
+
+	// The search modes new documents start with.
+	regex      bool
+	ignoreCase bool
+
+	// err and msg are shown in the footer until the next key press.
+	err error
+	msg string
+}
+
+// tab returns the active tab.
+func (m model) tab() *tab {
+	return m.tabs[m.active]
+}
+
+// doc returns the document in the active tab.
+func (m model) doc() *document {
+	return m.tab().doc
+}
+
+// opened prepares a freshly loaded document for the pager.
+func (m *model) opened(d *document) {
+	if m.slides {
+		d.deck = newDeck(d.body)
+	}
+	if m.log {
+		d.log = true
+		d.setContent(d.content)
+	}
+	m.store.attach(d)
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	var cmds []tea.Cmd
+	if m.follow != nil {
+		cmds = append(cmds, m.follow.wait())
+	}
+	if m.browser != nil {
+		cmds = append(cmds, m.browser.findFiles)
+	}
+	if m.large != nil {
+		cmds = append(cmds, m.large.indexNext())
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -53,72 +142,425 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds []tea.Cmd
 	)
 
+	switch msg := msg.(type) {
+	case reloadMsg:
+		// Documents in the history of a tab are reloaded too, they are
+		// laid out again once we go back to them.
+		reloaded := false
+		for _, doc := range m.documents() {
+			if msg.path != "" && doc.path != msg.path {
+				continue
+			}
+			doc.reloadErr = msg.err
+			if msg.err == nil {
+				doc.reload(msg.content)
+				doc.reloadedAt = msg.at
+				reloaded = true
+			}
+		}
+		// The front matter, the notes and the diff may have changed along
+		// with the document.
+		if reloaded {
+			m.layout()
+		}
+		return m, m.follow.wait()
+
+	case copiedMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("could not copy: %w", msg.err)
+		} else {
+			m.msg = fmt.Sprintf("copied %d lines", msg.lines)
+		}
+		return m, nil
+
+	case openedMsg:
+		switch {
+		case errors.Is(msg.err, errNoOpener):
+			m.msg = msg.url
+		case msg.err != nil:
+			m.err = fmt.Errorf("could not open %s: %w", msg.url, msg.err)
+		default:
+			m.msg = "opened " + msg.url
+		}
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		// Since this program is using the full size of the viewport we need
+		// to wait until we've received the window dimensions before we can
+		// size the viewports. The initial dimensions come in quickly, though
+		// asynchronously, which is why we wait for them here.
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		m.ready = true
+	}
+
+	if m.browsing {
+		return m.updateBrowser(msg)
+	}
+	if m.diff != nil {
+		return m.updateDiff(msg)
+	}
+	if m.large != nil {
+		return m.updateLarge(msg)
+	}
+
+	d := m.doc()
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if k := msg.String(); k == "ctrl+c" || k == "q" || k == "esc" {
+		m.err = nil
+		m.msg = ""
+		d.changed = false
+		if d.search.prompting {
+			return m, d.updateSearch(msg)
+		}
+		if m.command.prompting {
+			return m.updateCommand(msg)
+		}
+		if m.bookmark != nil {
+			action := m.bookmark
+			m.bookmark = nil
+			name := msg.String()
+			switch {
+			case !isBookmarkName(name):
+			case action == &keys.SetBookmark:
+				d.setBookmark(name)
+				m.msg = "bookmark " + name + " set"
+			default:
+				m.err = d.gotoBookmark(name)
+			}
+			return m, nil
+		}
+		if d.deck != nil {
+			switch {
+			case key.Matches(msg, keys.NextSlide):
+				if d.moveSlide(1, m.style) && d.deck.showNotes {
+					m.layout()
+				}
+				return m, nil
+			case key.Matches(msg, keys.PrevSlide):
+				if d.moveSlide(-1, m.style) && d.deck.showNotes {
+					m.layout()
+				}
+				return m, nil
+			case key.Matches(msg, keys.Notes):
+				d.deck.showNotes = !d.deck.showNotes
+				m.layout()
+				return m, nil
+			}
+		}
+		switch {
+		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
+		case key.Matches(msg, keys.Search):
+			return m, d.startSearch()
+		case key.Matches(msg, keys.Command):
+			return m, m.startCommand()
+		case key.Matches(msg, keys.NextBlock):
+			m.err = d.selectBlock()
+			return m, nil
+		case key.Matches(msg, keys.Yank):
+			cmd, err := d.yankBlock()
+			m.err = err
+			return m, cmd
+		case key.Matches(msg, keys.SetBookmark):
+			m.bookmark = &keys.SetBookmark
+			return m, nil
+		case key.Matches(msg, keys.GotoBookmark):
+			m.bookmark = &keys.GotoBookmark
+			return m, nil
+		case key.Matches(msg, keys.FrontMatter):
+			m.rawMeta = !m.rawMeta
+			m.layout()
+			return m, nil
+		case key.Matches(msg, keys.LineNumbers):
+			m.lineNumbers = !m.lineNumbers
+			m.layout()
+			return m, nil
+		case key.Matches(msg, keys.Wrap):
+			m.wrap = !m.wrap
+			m.layout()
+			return m, nil
+		case d.log && key.Matches(msg, keys.HideDebug):
+			m.hideDebug = !m.hideDebug
+			m.layout()
+			return m, nil
+		case d.log && key.Matches(msg, keys.HideInfo):
+			m.hideInfo = !m.hideInfo
+			m.layout()
+			return m, nil
+		case !d.wrap && key.Matches(msg, keys.Left):
+			d.scrollColumns(-horizontalStep)
+			return m, nil
+		case !d.wrap && key.Matches(msg, keys.Right):
+			d.scrollColumns(horizontalStep)
+			return m, nil
+		case key.Matches(msg, keys.NextMatch):
+			d.search.next()
+			d.showMatch()
+			return m, nil
+		case key.Matches(msg, keys.PrevMatch):
+			d.search.prev()
+			d.showMatch()
+			return m, nil
+		case key.Matches(msg, keys.NextTab):
+			m.active = (m.active + 1) % len(m.tabs)
+			m.toc.selected = max(0, currentHeading(m.doc().headings, m.doc().viewport.YOffset))
+			return m, nil
+		case key.Matches(msg, keys.PrevTab):
+			m.active = (m.active - 1 + len(m.tabs)) % len(m.tabs)
+			m.toc.selected = max(0, currentHeading(m.doc().headings, m.doc().viewport.YOffset))
+			return m, nil
+		case key.Matches(msg, keys.TOC):
+			m.toc.visible = !m.toc.visible
+			m.toc.selected = max(0, currentHeading(d.headings, d.viewport.YOffset))
+			m.layout()
+			return m, nil
+		}
+		if m.toc.visible {
+			switch {
+			case key.Matches(msg, keys.Down):
+				m.toc.selected = min(m.toc.selected+1, max(0, len(d.headings)-1))
+				return m, nil
+			case key.Matches(msg, keys.Up):
+				m.toc.selected = max(m.toc.selected-1, 0)
+				return m, nil
+			case key.Matches(msg, keys.TOCJump):
+				d.jumpToHeading(m.toc.selected)
+				return m, nil
+			}
+		}
+		switch {
+		case key.Matches(msg, keys.NextLink):
+			d.selectLink(1)
+			return m, nil
+		case key.Matches(msg, keys.PrevLink):
+			d.selectLink(-1)
+			return m, nil
+		case key.Matches(msg, keys.OpenLink):
+			cmd, err := m.openLink()
+			m.err = err
+			return m, cmd
+		case key.Matches(msg, keys.Back):
+			if m.tab().goBack() {
+				m.layout()
+			} else if m.browser != nil {
+				m.browsing = true
+			}
+			return m, nil
+		case key.Matches(msg, keys.Browse):
+			if m.browser != nil {
+				m.browsing = true
+			}
+			return m, nil
+		case key.Matches(msg, keys.Forward):
+			if m.tab().goForward() {
+				m.layout()
+			}
+			return m, nil
 		}
 
-	case tea.WindowSizeMsg:
-		headerHeight := lipgloss.Height(m.headerView())
-		footerHeight := lipgloss.Height(m.footerView())
-		verticalMarginHeight := headerHeight + footerHeight
-
-		if !m.ready {
-			// Since this program is using the full size of the viewport we
-			// need to wait until we've received the window dimensions before
-			// we can initialize the viewport. The initial dimensions come in
-			// quickly, though asynchronously, which is why we wait for them
-			// here.
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMarginHeight)
-			m.viewport.YPosition = headerHeight
-			m.viewport.HighPerformanceRendering = useHighPerformanceRenderer
-			m.viewport.SetContent(m.content)
-			m.ready = true
-
-			// This is only necessary for high performance rendering, which in
-			// most cases you won't need.
-			//
-			// Render the viewport one line below the header.
-			m.viewport.YPosition = headerHeight + 1
-		} else {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMarginHeight
+	case tea.MouseMsg:
+		if cmd, ok := m.updateMouse(msg); ok {
+			return m, cmd
 		}
 
+	case tea.WindowSizeMsg:
 		if useHighPerformanceRenderer {
 			// Render (or re-render) the whole viewport. Necessary both to
 			// initialize the viewport and when the window is resized.
 			//
 			// This is needed for high-performance rendering only.
-			cmds = append(cmds, viewport.Sync(m.viewport))
+			cmds = append(cmds, viewport.Sync(d.viewport))
 		}
 	}
 
+	// Keep the search prompt's cursor blinking
+	if d.search.prompting {
+		d.search.input, cmd = d.search.input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if m.command.prompting {
+		m.command.input, cmd = m.command.input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
 	// Handle keyboard and mouse events in the viewport
-	m.viewport, cmd = m.viewport.Update(msg)
+	d.viewport, cmd = d.viewport.Update(msg)
 	cmds = append(cmds, cmd)
 
 	return m, tea.Batch(cmds...)
 }
 
+// layout sizes the viewports to the window, leaving room for the header,
+// the footer, the table of contents and the speaker notes, if they are shown.
+func (m *model) layout() {
+	headerHeight := lipgloss.Height(m.headerView())
+	footerHeight := lipgloss.Height(m.footerView())
+	verticalMarginHeight := headerHeight + footerHeight
+
+	width := m.width
+	if m.toc.visible {
+		width -= sidebarWidth(m.width)
+	}
+	for _, t := range m.tabs {
+		doc := t.doc
+		doc.lineNumbers = m.lineNumbers
+		doc.wrap = m.wrap
+		doc.hideDebug, doc.hideInfo = m.hideDebug, m.hideInfo
+		height := m.height - verticalMarginHeight
+		if notes := doc.notesView(m.width); notes != "" {
+			height -= lipgloss.Height(notes)
+		}
+		metaHeight := 0
+		if meta := doc.metaView(m.width, m.height, m.rawMeta); meta != "" {
+			metaHeight = lipgloss.Height(meta)
+		}
+		height -= metaHeight
+		doc.setSize(width, max(0, height), m.style)
+
+		// This is only necessary for high performance rendering, which in
+		// most cases you won't need.
+		//
+		// Render the viewport one line below the header.
+		doc.viewport.YPosition = headerHeight + metaHeight + 1
+	}
+	if m.browser != nil {
+		m.browser.list.SetSize(m.width, m.height-verticalMarginHeight)
+	}
+	if m.diff != nil {
+		m.diff.setSize(m.width, max(0, m.height-verticalMarginHeight), m.style)
+	}
+	if m.large != nil {
+		m.large.setSize(m.width, max(0, m.height-verticalMarginHeight))
+	}
+}
+
 func (m model) View() string {
 	if !m.ready {
 		return "\n  Initializing..."
 	}
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+	if m.browsing {
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.browser.list.View(), m.footerView())
+	}
+	if m.diff != nil {
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.diff.view(), m.footerView())
+	}
+	if m.large != nil {
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.large.view(), m.footerView())
+	}
+	body := m.doc().viewport.View()
+	if m.toc.visible {
+		d := m.doc()
+		sidebar := m.toc.view(d.headings, currentHeading(d.headings, d.viewport.YOffset), sidebarWidth(m.width), d.viewport.Height)
+		body = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, body)
+	}
+	if notes := m.doc().notesView(m.width); notes != "" {
+		body = lipgloss.JoinVertical(lipgloss.Left, body, notes)
+	}
+	header := m.headerView()
+	if meta := m.doc().metaView(m.width, m.height, m.rawMeta); meta != "" {
+		header = lipgloss.JoinVertical(lipgloss.Left, header, meta)
+	}
+	return fmt.Sprintf("%s\n%s\n%s", header, body, m.footerView())
 }
 
 func (m model) headerView() string {
-	title := titleStyle.Render("Mr. Pager")
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
+	title := titleStyle.Render(m.title)
+	width := m.width - lipgloss.Width(title)
+	tabs := ansi.Truncate(m.tabsView(), width, "…")
+	line := strings.Repeat("─", max(0, width-lipgloss.Width(tabs)))
+	return lipgloss.JoinHorizontal(lipgloss.Center, title, tabs, line)
+}
+
+// tabsView renders one tab per document. With a single document there is
+// nothing to switch between, so we leave the header as it is.
+func (m model) tabsView() string {
+	if m.diff != nil {
+		return "─" + tabStyle.Render(m.diff.before.name+" → "+m.diff.after.name)
+	}
+	if m.large != nil {
+		return "─" + tabStyle.Render(m.large.name)
+	}
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	for i, t := range m.tabs {
+		b.WriteString("─")
+		if i == m.active {
+			b.WriteString(activeTabStyle.Render(t.doc.name))
+		} else {
+			b.WriteString(tabStyle.Render(t.doc.name))
+		}
+	}
+	return b.String()
 }
 
 func (m model) footerView() string {
-	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
+	if m.browsing {
+		status := "browse"
+		if m.err != nil {
+			status = m.err.Error()
+		}
+		return m.statusLine("", status)
+	}
+	if m.diff != nil {
+		status := fmt.Sprintf("%s %3.f%%", m.diff.summary(), m.diff.left.ScrollPercent()*100)
+		if m.err != nil {
+			status = m.err.Error() + " " + status
+		}
+		return m.statusLine("", status)
+	}
+	if m.large != nil {
+		return m.largeFooter()
+	}
+
+	d := m.doc()
+	status := strings.Join(d.stats(m.footer), " · ")
+	if !d.wrap {
+		status = strings.TrimSpace(fmt.Sprintf("col %d %s", d.xOffset+1, status))
+	}
+	if s := d.logStatus(); s != "" {
+		status = s + " " + status
+	}
+	if s := d.search.status(); s != "" {
+		status = s + " " + status
+	}
+	switch {
+	case m.err != nil:
+		status = m.err.Error() + " " + status
+	case m.msg != "":
+		status = m.msg + " " + status
+	case d.changed:
+		status = "changed since last visit " + status
+	case d.reloadErr != nil:
+		status = "reload failed " + status
+	case !d.reloadedAt.IsZero():
+		status = "reloaded at " + d.reloadedAt.Format("15:04:05") + " " + status
+	}
+
+	var prompt string
+	switch {
+	case d.search.prompting:
+		prompt = d.search.input.View() + " " + d.search.modes() + " "
+	case m.command.prompting:
+		prompt = m.command.input.View() + " "
+	case m.bookmark == &keys.SetBookmark:
+		prompt = "set bookmark: "
+	case m.bookmark == &keys.GotoBookmark:
+		prompt = "go to bookmark: "
+	}
+	return m.statusLine(prompt, status)
+}
+
+// statusLine renders the footer with the prompt on the left and the status
+// in a box on the right.
+func (m model) statusLine(prompt, status string) string {
+	status = ansi.Truncate(status, max(0, m.width-infoStyle.GetHorizontalFrameSize()), "…")
+	info := infoStyle.Render(status)
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(prompt)-lipgloss.Width(info)))
+	return lipgloss.JoinHorizontal(lipgloss.Center, prompt, line, info)
 }
 
 func max(a, b int) int {
@@ -130,22 +572,186 @@ func max(a, b int) int {
 
 func main() {
 	mdFile := kingpin.Flag("markdown-file", "Path under which to expose metrics.").Short('m').String()
+	regex := kingpin.Flag("regex", "Interpret search queries as regular expressions.").Bool()
+	ignoreCase := kingpin.Flag("ignore-case", "Search case-insensitively.").Short('i').Bool()
+	follow := kingpin.Flag("follow", "Reload the documents whenever they change on disk.").Short('f').Bool()
+	diff := kingpin.Flag("diff", "Show the changes between two documents side by side.").Bool()
+	wrap := kingpin.Flag("wrap", "Wrap long lines, use --no-wrap to scroll sideways instead.").Default("true").Bool()
+	logMode := kingpin.Flag("log", "Page the documents as logs, with colored levels that can be hidden, instead of rendering Markdown.").Short('l').Bool()
+	slides := kingpin.Flag("slides", "Present the documents as slides, split at --- lines.").Bool()
+	renderTo := kingpin.Flag("render-to", "Print the rendered documents instead of paging them, as ansi, plain or html. Plain is the default when stdout is not a terminal.").Enum(formatANSI, formatPlain, formatHTML)
+	width := kingpin.Flag("width", "Width to wrap printed documents to, defaults to the terminal width or 80.").Int()
+	script := kingpin.Flag("script", "Play the key and mouse events of a script file without a terminal and write the screen, see README.md.").ExistingFile()
+	scriptOutput := kingpin.Flag("script-output", "File to write the screens of --script to.").Default("-").String()
+	scriptEvery := kingpin.Flag("script-every", "Write the screen after every step of --script, not only at the end.").Bool()
+	configFile := kingpin.Flag("config", "Path of the config file.").PlaceHolder(defaultConfigPath()).String()
+	files := kingpin.Arg("files", "Markdown files or s3://, gs://, azblob://, file:// and mem:// URLs to page through, use - to read from stdin.").Strings()
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
-	content, err := ioutil.ReadFile(*mdFile)
+
+	paths := *files
+	if *mdFile != "" {
+		paths = append([]string{*mdFile}, paths...)
+	}
+	if len(paths) == 0 {
+		kingpin.Fatalf("no documents given, try --help")
+	}
+
+	configPath := *configFile
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	}
+	cfg, err := loadConfig(configPath, *configFile != "")
 	if err != nil {
-		fmt.Println("could not load file:", err)
-		os.Exit(1)
+		kingpin.Fatalf("%s", err)
+	}
+	var steps []scriptStep
+	if *script != "" {
+		if *follow {
+			kingpin.Fatalf("--script and --follow cannot be combined")
+		}
+		f, err := os.Open(*script)
+		if err != nil {
+			kingpin.Fatalf("%s", err)
+		}
+		steps, err = parseScript(f)
+		f.Close()
+		if err != nil {
+			kingpin.Fatalf("%s: %s", *script, err)
+		}
+		// The screens must not depend on the terminal we happen to run in.
+		lipgloss.SetColorProfile(termenv.TrueColor)
+		lipgloss.SetHasDarkBackground(true)
+	}
+	th, err := cfg.theme()
+	if err != nil {
+		kingpin.Fatalf("%s", err)
+	}
+	th.apply()
+	if err := keys.remap(cfg.Keys); err != nil {
+		kingpin.Fatalf("%s", err)
+	}
+
+	if *logMode && *slides {
+		kingpin.Fatalf("--log and --slides cannot be combined")
+	}
+	if *script == "" && (*renderTo != "" || !isTerminal(os.Stdout)) {
+		format := *renderTo
+		if format == "" {
+			format = formatPlain
+		}
+		w := *width
+		if w <= 0 {
+			w = renderWidth(os.Stdout)
+		}
+		if err := export(os.Stdout, paths, format, w, th.Glamour, *logMode); err != nil {
+			kingpin.Errorf("%s", err)
+			var f failure
+			if errors.As(err, &f) {
+				os.Exit(f.code)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	m := model{title: cfg.Title, style: th.Glamour, command: newCommand(), footer: cfg.Footer, wrap: *wrap, slides: *slides, log: *logMode, regex: *regex, ignoreCase: *ignoreCase}
+	// Scripts start fresh and leave no reading positions behind.
+	if *script == "" {
+		if st, err := openStore(); err == nil {
+			m.store = st
+		} else if st != nil {
+			// Start over with an empty state rather than not at all.
+			m.store = st
+			m.err = fmt.Errorf("could not read reading positions: %w", err)
+		}
+	}
+
+	var docs []*document
+	for _, path := range paths {
+		if isDir(path) {
+			if len(paths) > 1 {
+				kingpin.Fatalf("%s is a directory, directories can only be browsed on their own", path)
+			}
+			m.browser = newBrowser(path)
+			m.browsing = true
+			break
+		}
+		if isLargeFile(path) {
+			if len(paths) > 1 || *diff || *slides || *follow {
+				kingpin.Fatalf("%s is too large to render, large files can only be paged on their own, without --diff, --slides or --follow", path)
+			}
+			l, err := openLargeFile(path, newSearch(*regex, *ignoreCase))
+			if err != nil {
+				kingpin.Errorf("could not load document: %s", err)
+				os.Exit(exitLoadFailed)
+			}
+			defer l.close()
+			l.log = *logMode
+			m.large = l
+			break
+		}
+		doc, err := loadDocument(path, newSearch(*regex, *ignoreCase))
+		if err != nil {
+			kingpin.Errorf("could not load document: %s", err)
+			os.Exit(exitLoadFailed)
+		}
+		m.opened(doc)
+		docs = append(docs, doc)
+		m.tabs = append(m.tabs, newTab(doc))
+	}
+
+	if *diff {
+		if *slides {
+			kingpin.Fatalf("--diff and --slides cannot be combined")
+		}
+		if len(docs) != 2 {
+			kingpin.Fatalf("--diff needs exactly two documents, the old and the new one")
+		}
+		m.diff = newDiffView(docs[0], docs[1])
+	}
+
+	if *follow {
+		f, err := newFollower(docs)
+		if err != nil {
+			fmt.Println("could not follow files:", err)
+			os.Exit(1)
+		}
+		m.follow = f
+	}
+
+	if *script != "" {
+		if m.large != nil {
+			m.large.indexAll()
+		}
+		w := os.Stdout
+		if *scriptOutput != "-" {
+			if w, err = os.Create(*scriptOutput); err != nil {
+				kingpin.Fatalf("%s", err)
+			}
+			defer w.Close()
+		}
+		if err := runScript(m, steps, w, *scriptEvery); err != nil {
+			kingpin.Fatalf("could not write screens: %s", err)
+		}
+		return
 	}
 
 	p := tea.NewProgram(
-		model{content: string(content)},
-		tea.WithAltScreen(),       // use the full size of the terminal in its "alternate screen buffer"
-		tea.WithMouseCellMotion(), // turn on mouse support so we can track the mouse wheel
+		m,
+		tea.WithOutput(stdout),   // shared with copyToClipboard, see stdout
+		tea.WithAltScreen(),      // use the full size of the terminal in its "alternate screen buffer"
+		tea.WithMouseAllMotion(), // turn on mouse support so we can track the wheel, clicks and hovering
 	)
 
-	if err := p.Start(); err != nil {
+	final, err := p.Run()
+	if err != nil {
 		fmt.Println("could not run program:", err)
 		os.Exit(1)
 	}
+	if fm, ok := final.(model); ok {
+		if err := fm.store.save(fm.savedDocuments()); err != nil {
+			fmt.Fprintln(os.Stderr, "could not save reading positions:", err)
+		}
+	}
 }
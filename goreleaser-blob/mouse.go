@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var hoverStyle = lipgloss.NewStyle().Underline(true)
+
+// errNoOpener means there is no program to open links outside the pager.
+var errNoOpener = errors.New("no program to open links with")
+
+// openedMsg reports how opening a link outside the pager went.
+type openedMsg struct {
+	url string
+	err error
+}
+
+// openers are the programs that open a URL with whatever the system uses
+// for it, by operating system, in the order we try them.
+var openers = map[string][][]string{
+	"darwin":  {{"open"}},
+	"windows": {{"rundll32", "url.dll,FileProtocolHandler"}},
+	"linux":   {{"xdg-open"}, {"wslview"}},
+}
+
+// openURL opens the URL with the system opener. We don't wait for it, the
+// browser may well keep running after we quit.
+func openURL(u string) tea.Cmd {
+	return func() tea.Msg {
+		for _, args := range openers[runtime.GOOS] {
+			path, err := exec.LookPath(args[0])
+			if err != nil {
+				continue
+			}
+			cmd := exec.Command(path, append(args[1:], u)...)
+			if err := cmd.Start(); err != nil {
+				return openedMsg{url: u, err: err}
+			}
+			go cmd.Wait()
+			return openedMsg{url: u}
+		}
+		return openedMsg{url: u, err: errNoOpener}
+	}
+}
+
+// docPosition maps a cell on the screen to a position in the rendered
+// document, taking the header, the table of contents, the line numbers and
+// the scroll position into account. It reports false for cells outside the
+// document.
+func (m model) docPosition(x, y int) (position, bool) {
+	d := m.doc()
+	top := lipgloss.Height(m.headerView())
+	if meta := d.metaView(m.width, m.height, m.rawMeta); meta != "" {
+		top += lipgloss.Height(meta)
+	}
+	left := 0
+	if m.toc.visible {
+		left += sidebarWidth(m.width)
+	}
+	left += d.gutterWidth()
+	row := y - top
+	if row < 0 || row >= d.viewport.Height || x < left {
+		return position{}, false
+	}
+	p := position{line: d.viewport.YOffset + row, col: x - left + d.xOffset}
+	if p.line >= len(d.lines) {
+		return position{}, false
+	}
+	return p, true
+}
+
+// contains reports whether the position is on the text of the link.
+func (l link) contains(p position) bool {
+	switch {
+	case p.line < l.start.line || p.line > l.end.line:
+		return false
+	case p.line == l.start.line && p.col < l.start.col:
+		return false
+	case p.line == l.end.line && p.col >= l.end.col:
+		return false
+	}
+	return true
+}
+
+// linkAt returns the link at the position, or -1.
+func (d *document) linkAt(p position) int {
+	for i, l := range d.links {
+		if l.contains(p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// headingAt returns the heading on the line of the position, or -1.
+func (d *document) headingAt(p position) int {
+	for i, h := range d.headings {
+		if h.line == p.line {
+			return i
+		}
+	}
+	return -1
+}
+
+// hover highlights the link or heading under the mouse pointer, if any.
+func (d *document) hover(p position, ok bool) {
+	link, heading := -1, -1
+	if ok {
+		if link = d.linkAt(p); link < 0 {
+			heading = d.headingAt(p)
+		}
+	}
+	if link != d.hoverLink || heading != d.hoverHeading {
+		d.hoverLink, d.hoverHeading = link, heading
+		d.updateContent()
+	}
+}
+
+// highlightHover returns a copy of lines with the link or heading under the
+// mouse pointer highlighted.
+func (d *document) highlightHover(lines []string) []string {
+	switch {
+	case d.hoverLink >= 0 && d.hoverLink < len(d.links):
+		return styleLink(lines, d.links[d.hoverLink], hoverStyle)
+	case d.hoverHeading >= 0 && d.hoverHeading < len(d.headings):
+		line := d.headings[d.hoverHeading].line
+		if line < 0 || line >= len(lines) {
+			return lines
+		}
+		out := make([]string, len(lines))
+		copy(out, lines)
+		plain := ansi.Strip(out[line])
+		start := ansi.StringWidth(plain) - ansi.StringWidth(strings.TrimLeft(plain, " "))
+		end := ansi.StringWidth(strings.TrimRight(plain, " "))
+		if end > start {
+			out[line] = lipgloss.StyleRanges(out[line], lipgloss.NewRange(start, end, hoverStyle))
+		}
+		return out
+	}
+	return lines
+}
+
+// updateMouse handles clicks on links, which open them, and on headings,
+// which fold or unfold their section, as well as hovering over them and
+// scrolling sideways. It reports whether it handled the event, the viewport
+// takes care of the mouse wheel.
+func (m *model) updateMouse(msg tea.MouseMsg) (tea.Cmd, bool) {
+	d := m.doc()
+	switch {
+	case msg.Action == tea.MouseActionMotion:
+		p, ok := m.docPosition(msg.X, msg.Y)
+		d.hover(p, ok)
+		return nil, true
+	case msg.Action != tea.MouseActionPress:
+		return nil, false
+	case msg.Button == tea.MouseButtonLeft:
+		p, ok := m.docPosition(msg.X, msg.Y)
+		if !ok {
+			return nil, false
+		}
+		m.err, m.msg = nil, ""
+		if i := d.linkAt(p); i >= 0 {
+			d.selectedLink = i
+			d.updateContent()
+			cmd, err := m.openLink()
+			m.err = err
+			return cmd, true
+		}
+		if i := d.headingAt(p); i >= 0 {
+			d.toggleFold(i, m.style)
+			d.hover(p, true)
+			return nil, true
+		}
+	case d.wrap:
+		// Nothing to scroll sideways.
+	case msg.Button == tea.MouseButtonWheelLeft, msg.Shift && msg.Button == tea.MouseButtonWheelUp:
+		d.scrollColumns(-horizontalStep)
+		return nil, true
+	case msg.Button == tea.MouseButtonWheelRight, msg.Shift && msg.Button == tea.MouseButtonWheelDown:
+		d.scrollColumns(horizontalStep)
+		return nil, true
+	}
+	return nil, false
+}
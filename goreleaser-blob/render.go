@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// glamourGutter is the number of columns we keep free on the right side of
+// the viewport, so that glamour's own document margin does not cause lines
+// to wrap a second time in the viewport.
+const glamourGutter = 2
+
+// detectStyle picks the glamour style matching the terminal background. It
+// has to be called before the Bubble Tea program starts, as it queries the
+// terminal and would otherwise race with the program's input reader.
+func detectStyle() string {
+	if lipgloss.HasDarkBackground() {
+		return "dark"
+	}
+	return "light"
+}
+
+// renderMarkdown turns the given Markdown into styled terminal output,
+// word wrapped to the given width. The style is either the name of a
+// glamour style or the path to a JSON style file.
+func renderMarkdown(content string, width int, style string) (string, error) {
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStylePath(style),
+		glamour.WithWordWrap(max(0, width-glamourGutter)),
+	)
+	if err != nil {
+		return "", err
+	}
+	return r.Render(content)
+}
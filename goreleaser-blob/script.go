@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultScriptWidth and defaultScriptHeight are the size of the screen a
+// script plays on unless it sets one.
+const (
+	defaultScriptWidth  = 80
+	defaultScriptHeight = 24
+)
+
+// scriptStep is one line of a --script file and the messages it sends.
+type scriptStep struct {
+	text string
+	msgs []tea.Msg
+	// frame writes the screen without sending anything.
+	frame bool
+}
+
+// keyTypes maps the names bubbletea gives keys, like "enter" or "ctrl+d",
+// back to their types.
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{}
+	// Special keys are negative, control keys run up to DEL, i.e. backspace.
+	for t := tea.KeyType(-100); t <= tea.KeyBackspace; t++ {
+		if name := t.String(); name != "" && t != tea.KeyRunes {
+			types[name] = t
+		}
+	}
+	types["space"] = tea.KeySpace
+	return types
+}()
+
+// parseKey turns a key as it is written in key bindings into a key message.
+func parseKey(s string) (tea.KeyMsg, error) {
+	var k tea.Key
+	if rest, ok := strings.CutPrefix(s, "alt+"); ok && rest != "" {
+		k.Alt = true
+		s = rest
+	}
+	if t, ok := keyTypes[s]; ok {
+		k.Type = t
+		return tea.KeyMsg(k), nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return tea.KeyMsg{}, fmt.Errorf("unknown key %q", s)
+	}
+	k.Type = tea.KeyRunes
+	k.Runes = []rune(s)
+	return tea.KeyMsg(k), nil
+}
+
+// mouseEvents are the mouse events a script can send, with the messages
+// they turn into.
+var mouseEvents = map[string][]tea.MouseEvent{
+	"click":      {{Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}, {Button: tea.MouseButtonLeft, Action: tea.MouseActionRelease}},
+	"press":      {{Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}},
+	"release":    {{Button: tea.MouseButtonLeft, Action: tea.MouseActionRelease}},
+	"right":      {{Button: tea.MouseButtonRight, Action: tea.MouseActionPress}, {Button: tea.MouseButtonRight, Action: tea.MouseActionRelease}},
+	"move":       {{Button: tea.MouseButtonNone, Action: tea.MouseActionMotion}},
+	"wheel-up":   {{Button: tea.MouseButtonWheelUp, Action: tea.MouseActionPress}},
+	"wheel-down": {{Button: tea.MouseButtonWheelDown, Action: tea.MouseActionPress}},
+}
+
+// parseScript reads a script. Every line is one step, blank lines and lines
+// starting with # are skipped:
+//
+//	size 100x30          resize the screen
+//	key j ctrl+d enter   press keys, named like in the config file
+//	type :120            type text, one key per character
+//	mouse click 10 5     click, press, release, right, move, wheel-up or
+//	                     wheel-down at a column and row, with shift+, alt+
+//	                     or ctrl+ in front to hold modifiers
+//	frame                write the screen
+func parseScript(r io.Reader) ([]scriptStep, error) {
+	var steps []scriptStep
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		step := scriptStep{text: text}
+		cmd, args, _ := strings.Cut(text, " ")
+		args = strings.TrimSpace(args)
+		fields := strings.Fields(args)
+		switch cmd {
+		case "size":
+			w, h, ok := strings.Cut(args, "x")
+			width, werr := strconv.Atoi(w)
+			height, herr := strconv.Atoi(h)
+			if !ok || werr != nil || herr != nil || width <= 0 || height <= 0 {
+				return nil, fmt.Errorf("line %d: size: want WIDTHxHEIGHT, got %q", n, args)
+			}
+			step.msgs = append(step.msgs, tea.WindowSizeMsg{Width: width, Height: height})
+		case "key":
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: key: no keys given", n)
+			}
+			for _, f := range fields {
+				k, err := parseKey(f)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				step.msgs = append(step.msgs, k)
+			}
+		case "type":
+			for _, r := range args {
+				step.msgs = append(step.msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			}
+		case "mouse":
+			var mod tea.MouseEvent
+			name := firstField(fields)
+			for _, prefix := range []string{"shift+", "alt+", "ctrl+"} {
+				if rest, ok := strings.CutPrefix(name, prefix); ok {
+					name = rest
+					mod.Shift = mod.Shift || prefix == "shift+"
+					mod.Alt = mod.Alt || prefix == "alt+"
+					mod.Ctrl = mod.Ctrl || prefix == "ctrl+"
+				}
+			}
+			events, ok := mouseEvents[name]
+			if !ok || len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: mouse: want EVENT X Y, got %q", n, args)
+			}
+			x, xerr := strconv.Atoi(fields[1])
+			y, yerr := strconv.Atoi(fields[2])
+			if xerr != nil || yerr != nil {
+				return nil, fmt.Errorf("line %d: mouse: invalid position %s %s", n, fields[1], fields[2])
+			}
+			for _, e := range events {
+				e.X, e.Y = x, y
+				e.Shift, e.Alt, e.Ctrl = mod.Shift, mod.Alt, mod.Ctrl
+				step.msgs = append(step.msgs, tea.MouseMsg(e))
+			}
+		case "frame":
+			step.frame = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown step %q, use size, key, type, mouse or frame", n, cmd)
+		}
+		steps = append(steps, step)
+	}
+	return steps, sc.Err()
+}
+
+func firstField(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// runScript plays the steps on m without a terminal and writes the screen
+// to w when the script is over, and with every, after every step as well.
+// Quitting ends the script early.
+//
+// Commands the model returns are not run: they are timers, file watchers
+// and the clipboard, which would make the frames depend on the machine.
+// Only what Init starts, like listing the files to browse, is run, so the
+// first frame has something to show.
+func runScript(m tea.Model, steps []scriptStep, w io.Writer, every bool) error {
+	m, _ = m.Update(tea.WindowSizeMsg{Width: defaultScriptWidth, Height: defaultScriptHeight})
+	for _, msg := range initMsgs(m.Init()) {
+		m, _ = m.Update(msg)
+	}
+
+	frames := 0
+	write := func(label string) error {
+		frames++
+		if every {
+			if _, err := fmt.Fprintf(w, "--- frame %d: %s\n", frames, label); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintln(w, m.View())
+		return err
+	}
+	if every {
+		if err := write("start"); err != nil {
+			return err
+		}
+	}
+	for _, s := range steps {
+		for _, msg := range s.msgs {
+			var cmd tea.Cmd
+			m, cmd = m.Update(msg)
+			if cmd != nil && isQuit(cmd) {
+				return write(s.text)
+			}
+		}
+		if s.frame || every {
+			if err := write(s.text); err != nil {
+				return err
+			}
+		}
+	}
+	if every || (len(steps) > 0 && steps[len(steps)-1].frame) {
+		return nil
+	}
+	return write("end")
+}
+
+// initMsgs runs the commands of Init and returns their messages.
+func initMsgs(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		return []tea.Msg{msg}
+	}
+	var msgs []tea.Msg
+	for _, c := range batch {
+		msgs = append(msgs, initMsgs(c)...)
+	}
+	return msgs
+}
+
+// isQuit reports whether cmd is tea.Quit. Functions can't be compared with
+// ==, so we compare their code pointers.
+func isQuit(cmd tea.Cmd) bool {
+	return reflect.ValueOf(cmd).Pointer() == reflect.ValueOf(tea.Quit).Pointer()
+}
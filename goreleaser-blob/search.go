@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	matchStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("0")).
+			Background(lipgloss.Color("11"))
+
+	currentMatchStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("208")).
+				Bold(true)
+)
+
+// match is a single search hit. Start and end are cell columns in the
+// rendered line, not byte offsets, so they can be used with lipgloss ranges.
+type match struct {
+	line       int
+	start, end int
+}
+
+// search holds the state of the less-like "/" search.
+type search struct {
+	input      textinput.Model
+	prompting  bool
+	query      string
+	regex      bool
+	ignoreCase bool
+	matches    []match
+	current    int
+	err        error
+}
+
+func newSearch(regex, ignoreCase bool) search {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	return search{
+		input:      ti,
+		regex:      regex,
+		ignoreCase: ignoreCase,
+	}
+}
+
+// compile turns the query into a regular expression, honoring the regex and
+// case-insensitive modes. Queries are matched a line at a time, so ^ and $
+// are the start and end of the line.
+func (s search) compile() (*regexp.Regexp, error) {
+	expr := s.query
+	if !s.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if s.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile("(?m)" + expr)
+}
+
+// find looks for the query in the given rendered lines. The lines are
+// matched without their ANSI styling, so what you search is what you see.
+func (s *search) find(lines []string) {
+	s.matches = nil
+	s.err = nil
+	if s.query == "" {
+		return
+	}
+	re, err := s.compile()
+	if err != nil {
+		s.err = err
+		return
+	}
+	for i, l := range lines {
+		plain := ansi.Strip(l)
+		for _, loc := range re.FindAllStringIndex(plain, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			s.matches = append(s.matches, match{
+				line:  i,
+				start: ansi.StringWidth(plain[:loc[0]]),
+				end:   ansi.StringWidth(plain[:loc[1]]),
+			})
+		}
+	}
+	if s.current >= len(s.matches) {
+		s.current = 0
+	}
+}
+
+// first selects the first match at or below the given line.
+func (s *search) first(line int) {
+	s.current = 0
+	for i, mt := range s.matches {
+		if mt.line >= line {
+			s.current = i
+			return
+		}
+	}
+}
+
+func (s *search) next() {
+	if len(s.matches) > 0 {
+		s.current = (s.current + 1) % len(s.matches)
+	}
+}
+
+func (s *search) prev() {
+	if len(s.matches) > 0 {
+		s.current = (s.current - 1 + len(s.matches)) % len(s.matches)
+	}
+}
+
+// highlight returns a copy of lines with all matches highlighted.
+func (s search) highlight(lines []string) []string {
+	if len(s.matches) == 0 {
+		return lines
+	}
+	out := make([]string, len(lines))
+	copy(out, lines)
+	var ranges []lipgloss.Range
+	for i, mt := range s.matches {
+		style := matchStyle
+		if i == s.current {
+			style = currentMatchStyle
+		}
+		ranges = append(ranges, lipgloss.NewRange(mt.start, mt.end, style))
+		if i == len(s.matches)-1 || s.matches[i+1].line != mt.line {
+			out[mt.line] = lipgloss.StyleRanges(lines[mt.line], ranges...)
+			ranges = nil
+		}
+	}
+	return out
+}
+
+// modes describes the active search modes, e.g. "[regex,nocase]".
+func (s search) modes() string {
+	switch {
+	case s.regex && s.ignoreCase:
+		return "[regex,nocase]"
+	case s.regex:
+		return "[regex]"
+	case s.ignoreCase:
+		return "[nocase]"
+	}
+	return ""
+}
+
+// status is shown in the footer whenever there is an active query.
+func (s search) status() string {
+	switch {
+	case s.query == "":
+		return ""
+	case s.err != nil:
+		return "bad pattern"
+	case len(s.matches) == 0:
+		return "no matches"
+	}
+	return fmt.Sprintf("match %d/%d", s.current+1, len(s.matches))
+}
+
+// updateSearch handles key presses while the search prompt is open. The
+// matches are refreshed on every keystroke to give incremental search.
+func (d *document) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, keys.SearchConfirm):
+		d.search.prompting = false
+		d.search.input.Blur()
+		return nil
+	case key.Matches(msg, keys.SearchCancel):
+		d.search.prompting = false
+		d.search.input.Blur()
+		d.search.query = ""
+		d.refreshSearch()
+		return nil
+	}
+
+	var cmd tea.Cmd
+	switch {
+	case key.Matches(msg, keys.ToggleRegex):
+		d.search.regex = !d.search.regex
+	case key.Matches(msg, keys.ToggleCase):
+		d.search.ignoreCase = !d.search.ignoreCase
+	default:
+		d.search.input, cmd = d.search.input.Update(msg)
+		d.search.query = d.search.input.Value()
+	}
+	d.refreshSearch()
+	d.search.first(d.searchFrom)
+	d.showMatch()
+	return cmd
+}
+
+// startSearch opens the search prompt. The search starts at the line that
+// is currently at the top of the viewport.
+func (d *document) startSearch() tea.Cmd {
+	d.search.prompting = true
+	d.search.input.SetValue("")
+	d.searchFrom = d.viewport.YOffset
+	return d.search.input.Focus()
+}
+
+// refreshSearch recomputes the matches and hands the highlighted lines to
+// the viewport.
+func (d *document) refreshSearch() {
+	d.search.find(d.lines)
+	d.updateContent()
+}
+
+// showMatch scrolls the viewport so the current match is visible.
+func (d *document) showMatch() {
+	if len(d.search.matches) == 0 {
+		return
+	}
+	d.updateContent()
+	mt := d.search.matches[d.search.current]
+	if mt.line < d.viewport.YOffset || mt.line >= d.viewport.YOffset+d.viewport.Height {
+		d.viewport.SetYOffset(mt.line)
+	}
+	d.showColumns(mt.start, mt.end)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var notesStyle = lipgloss.NewStyle().
+	Border(lipgloss.NormalBorder(), true, false, false, false).
+	Foreground(lipgloss.Color("244"))
+
+var (
+	slideSeparator = regexp.MustCompile(`^---\s*$`)
+	notesSeparator = regexp.MustCompile(`^\?\?\?\s*$`)
+	codeFence      = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+)
+
+// slide is a single slide of a deck. Everything after a "???" line is a
+// speaker note, which is not shown on the slide itself.
+type slide struct {
+	body  string
+	notes string
+}
+
+// deck is a document shown one slide at a time in --slides mode.
+type deck struct {
+	slides    []slide
+	current   int
+	showNotes bool
+}
+
+func newDeck(content string) *deck {
+	return &deck{slides: splitSlides(content)}
+}
+
+// splitSlides splits the document at "---" lines. To not mistake the
+// underline of a heading for a separator, it has to follow an empty line.
+// Separators in code blocks are left alone, and empty slides are dropped.
+func splitSlides(content string) []slide {
+	var (
+		slides []slide
+		body   []string
+		notes  []string
+		inNote bool
+		fence  string
+		prev   string
+	)
+	flush := func() {
+		s := slide{
+			body:  strings.TrimSpace(strings.Join(body, "\n")),
+			notes: strings.TrimSpace(strings.Join(notes, "\n")),
+		}
+		if s.body != "" || s.notes != "" {
+			slides = append(slides, s)
+		}
+		body, notes, inNote = nil, nil, false
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case fence == m[1]:
+				fence = ""
+			}
+		}
+		switch {
+		case fence == "" && slideSeparator.MatchString(line) && strings.TrimSpace(prev) == "":
+			flush()
+		case fence == "" && notesSeparator.MatchString(line):
+			inNote = true
+		case inNote:
+			notes = append(notes, line)
+		default:
+			body = append(body, line)
+		}
+		prev = line
+	}
+	flush()
+	if len(slides) == 0 {
+		slides = []slide{{}}
+	}
+	return slides
+}
+
+// slide returns the slide that is shown.
+func (dk *deck) slide() slide {
+	return dk.slides[dk.current]
+}
+
+// move goes delta slides forward or back and reports whether the slide
+// changed.
+func (dk *deck) move(delta int) bool {
+	next := min(max(dk.current+delta, 0), len(dk.slides)-1)
+	if next == dk.current {
+		return false
+	}
+	dk.current = next
+	return true
+}
+
+// reload splits the changed document again, staying on the same slide if it
+// is still there.
+func (dk *deck) reload(content string) {
+	dk.slides = splitSlides(content)
+	dk.current = min(dk.current, len(dk.slides)-1)
+}
+
+// center places the rendered slide in the middle of the given area. Slides
+// that don't fit are left where they are, so they can still be scrolled.
+func center(rendered string, width, height int) string {
+	lines := strings.Split(rendered, "\n")
+	plain := make([]string, len(lines))
+	for i, l := range lines {
+		plain[i] = strings.TrimRight(ansi.Strip(l), " ")
+	}
+	for len(plain) > 0 && plain[0] == "" {
+		lines, plain = lines[1:], plain[1:]
+	}
+	for len(plain) > 0 && plain[len(plain)-1] == "" {
+		lines, plain = lines[:len(lines)-1], plain[:len(plain)-1]
+	}
+
+	left, right := width, 0
+	for _, p := range plain {
+		if p == "" {
+			continue
+		}
+		left = min(left, ansi.StringWidth(p)-ansi.StringWidth(strings.TrimLeft(p, " ")))
+		right = max(right, ansi.StringWidth(p))
+	}
+	if shift := (width-(right-left))/2 - left; shift > 0 && right > left {
+		pad := strings.Repeat(" ", shift)
+		for i, l := range lines {
+			lines[i] = ansi.Truncate(pad+l, width, "")
+		}
+	}
+	if top := (height - len(lines)) / 2; top > 0 {
+		lines = append(make([]string, top), lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// moveSlide shows another slide of the deck, starting at its top.
+func (d *document) moveSlide(delta int, style string) bool {
+	if d.deck == nil || !d.deck.move(delta) {
+		return false
+	}
+	d.selectedLink = -1
+	d.selectedBlock = -1
+	d.setSize(d.viewport.Width, d.viewport.Height, style)
+	d.viewport.GotoTop()
+	return true
+}
+
+// notesView renders the speaker notes of the current slide, if they are
+// toggled on.
+func (d *document) notesView(width int) string {
+	if d.deck == nil || !d.deck.showNotes {
+		return ""
+	}
+	notes := d.deck.slide().notes
+	if notes == "" {
+		notes = "No notes"
+	}
+	return notesStyle.Width(width).Render(notes)
+}
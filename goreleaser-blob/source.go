@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+
+	// The providers goreleaser publishes to, plus the local and in-memory
+	// ones, which come in handy to try things out without any credentials.
+	_ "gocloud.dev/blob/azureblob"
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/gcsblob"
+	_ "gocloud.dev/blob/memblob"
+	_ "gocloud.dev/blob/s3blob"
+)
+
+// blobTimeout bounds how long we wait for a bucket to hand us a document.
+const blobTimeout = 30 * time.Second
+
+// blobSchemes are the URL schemes we open through the portable blob API.
+var blobSchemes = map[string]bool{
+	"s3":     true,
+	"gs":     true,
+	"azblob": true,
+	"file":   true,
+	"mem":    true,
+}
+
+// isBlobURL reports whether the document argument is a bucket URL rather
+// than a local path.
+func isBlobURL(arg string) bool {
+	u, err := url.Parse(arg)
+	return err == nil && blobSchemes[u.Scheme]
+}
+
+// splitBlobURL splits a document URL like s3://bucket/notes/v1.0.0.md?region=eu-central-1
+// into the URL of the bucket and the key of the object in it. For file://
+// URLs the directory of the file is the bucket.
+func splitBlobURL(arg string) (bucketURL, key string, err error) {
+	u, err := url.Parse(arg)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme == "file" {
+		dir, file := path.Split(u.Path)
+		bucket := url.URL{Scheme: u.Scheme, Path: dir, RawQuery: u.RawQuery}
+		return bucket.String(), file, nil
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return "", "", fmt.Errorf("%s does not name a document in the bucket", arg)
+	}
+	bucket := url.URL{Scheme: u.Scheme, Host: u.Host, RawQuery: u.RawQuery}
+	return bucket.String(), key, nil
+}
+
+// readBlob reads the document at the given bucket URL.
+func readBlob(ctx context.Context, arg string) ([]byte, error) {
+	bucketURL, key, err := splitBlobURL(arg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, blobTimeout)
+	defer cancel()
+
+	bucket, err := blob.OpenBucket(ctx, bucketURL)
+	if err != nil {
+		return nil, blobError(arg, err)
+	}
+	defer bucket.Close()
+
+	content, err := bucket.ReadAll(ctx, key)
+	if err != nil {
+		return nil, blobError(arg, err)
+	}
+	return content, nil
+}
+
+// blobError turns the errors of the different providers into a short
+// message. The provider errors tend to be long and mostly about the SDK
+// internals, so we only add them when we have nothing better to say.
+func blobError(arg string, err error) error {
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound:
+		return fmt.Errorf("%s: no such document", arg)
+	case gcerrors.PermissionDenied:
+		return fmt.Errorf("%s: access denied, check your credentials", arg)
+	case gcerrors.DeadlineExceeded:
+		return fmt.Errorf("%s: timed out after %s", arg, blobTimeout)
+	}
+	// Missing credentials are not classified by all providers, so we have
+	// to look at the message.
+	if strings.Contains(strings.ToLower(err.Error()), "credential") {
+		return fmt.Errorf("%s: no usable credentials found", arg)
+	}
+	return fmt.Errorf("%s: %w", arg, err)
+}
+
+// loadBlobDocument reads the document from a bucket.
+func loadBlobDocument(arg string, s search) (*document, error) {
+	content, err := readBlob(context.Background(), arg)
+	if err != nil {
+		return nil, err
+	}
+	_, key, _ := splitBlobURL(arg)
+	return newDocument(path.Base(key), string(content), s), nil
+}
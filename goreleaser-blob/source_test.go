@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gocloud.dev/blob/memblob"
+)
+
+func TestReadBlobFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readBlob(context.Background(), "file://"+filepath.ToSlash(dir)+"/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "# Hello\n" {
+		t.Errorf("got %q, want %q", got, "# Hello\n")
+	}
+}
+
+func TestReadBlobMissingKey(t *testing.T) {
+	arg := "file://" + filepath.ToSlash(t.TempDir()) + "/missing.md"
+	_, err := readBlob(context.Background(), arg)
+	if err == nil || err.Error() != arg+": no such document" {
+		t.Errorf("got error %v, want %q", err, arg+": no such document")
+	}
+}
+
+func TestBlobErrorNotFound(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+	if err := bucket.WriteAll(ctx, "notes.md", []byte("notes"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := bucket.ReadAll(ctx, "missing.md")
+	if got := blobError("mem://bucket/missing.md", err); got.Error() != "mem://bucket/missing.md: no such document" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestReadBlobMem(t *testing.T) {
+	// Every mem:// bucket starts out empty.
+	arg := "mem://bucket/README.md"
+	_, err := readBlob(context.Background(), arg)
+	if err == nil || err.Error() != arg+": no such document" {
+		t.Errorf("got error %v, want %q", err, arg+": no such document")
+	}
+}
+
+func TestIsBlobURL(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"s3://bucket/README.md":     true,
+		"gs://bucket/README.md":     true,
+		"azblob://bucket/README.md": true,
+		"file:///tmp/README.md":     true,
+		"mem://bucket/README.md":    true,
+		"ftp://host/README.md":      false,
+		"README.md":                 false,
+		"-":                         false,
+	} {
+		if got := isBlobURL(arg); got != want {
+			t.Errorf("isBlobURL(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
+
+func TestSplitBlobURL(t *testing.T) {
+	tests := []struct {
+		arg, bucket, key string
+	}{
+		{"s3://bucket/notes/v1.0.0.md?region=eu-central-1", "s3://bucket?region=eu-central-1", "notes/v1.0.0.md"},
+		{"gs://bucket/README.md", "gs://bucket", "README.md"},
+		{"file:///tmp/bucket/README.md", "file:///tmp/bucket/", "README.md"},
+	}
+	for _, tt := range tests {
+		bucket, key, err := splitBlobURL(tt.arg)
+		if err != nil {
+			t.Errorf("splitBlobURL(%q): %v", tt.arg, err)
+			continue
+		}
+		if bucket != tt.bucket || key != tt.key {
+			t.Errorf("splitBlobURL(%q) = %q, %q, want %q, %q", tt.arg, bucket, key, tt.bucket, tt.key)
+		}
+	}
+
+	if _, _, err := splitBlobURL("s3://bucket"); err == nil || !strings.Contains(err.Error(), "does not name a document") {
+		t.Errorf("got error %v for a bucket without a key", err)
+	}
+}
+
+func TestReadBlobBadScheme(t *testing.T) {
+	if _, err := readBlob(context.Background(), "nope://bucket/README.md"); err == nil {
+		t.Error("got no error for an unknown scheme")
+	}
+}
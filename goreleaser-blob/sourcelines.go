@@ -0,0 +1,185 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// sourceLine is a line of the Markdown source that renders some text: off is
+// where the line starts in the source, text is the first few words on it.
+type sourceLine struct {
+	off  int
+	text string
+}
+
+// sourceLineWords is how many words of a source line we look for.
+const sourceLineWords = 2
+
+// parseSourceLines returns the lines of the Markdown source that render any
+// text, in document order. Lines without letters or digits, like code fences,
+// rules and closing braces, are left out, since their text is too common to
+// find the right place for it.
+func parseSourceLines(source string) []sourceLine {
+	src := []byte(source)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	var lines []sourceLine
+	last := -1
+	add := func(seg text.Segment, t string) {
+		// Only the first words, long ones like URLs may be broken up.
+		words := strings.Fields(t)
+		t = strings.Join(words[:min(len(words), sourceLineWords)], " ")
+		start := strings.LastIndexByte(source[:seg.Start], '\n') + 1
+		if start <= last || strings.IndexFunc(t, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			return
+		}
+		lines = append(lines, sourceLine{off: start, text: t})
+		last = start
+	}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			segs := n.Lines()
+			for i := 0; i < segs.Len(); i++ {
+				seg := segs.At(i)
+				add(seg, string(seg.Value(src)))
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			// The parser doesn't know tables, whose rows are text with
+			// the cells between pipes, so we look for their first cell.
+			t := strings.TrimLeft(string(n.Segment.Value(src)), " \t|")
+			t, _, _ = strings.Cut(t, "|")
+			add(n.Segment, t)
+		}
+		return ast.WalkContinue, nil
+	})
+	return lines
+}
+
+// locateSourceLines finds the rendered line every source line starts on. It
+// returns the offset of the source line for every rendered line, or -1 for
+// lines that continue a wrapped source line or render none at all. Like
+// links, source lines are found in order, so repeated text does not throw us
+// off, and lines we can't find are skipped.
+func locateSourceLines(src []sourceLine, lines []string) []int {
+	p := newPlainText(lines)
+	rows := make([]int, len(lines))
+	for i := range rows {
+		rows[i] = -1
+	}
+	from := 0
+	for _, s := range src {
+		re, err := regexp.Compile(wordsPattern(s.text))
+		if err != nil {
+			continue
+		}
+		loc := re.FindStringIndex(p.text[from:])
+		if loc == nil {
+			continue
+		}
+		if row := p.position(from + loc[0]).line; rows[row] < 0 {
+			rows[row] = s.off
+		}
+		from += loc[1]
+	}
+	return rows
+}
+
+// wordsPattern is fieldsPattern for whole words, so that a line starting
+// with "a" does not match the "a" in the middle of a word.
+func wordsPattern(s string) string {
+	p := fieldsPattern(s)
+	if isASCIIWord(s[0]) {
+		p = `\b` + p
+	}
+	if isASCIIWord(s[len(s)-1]) {
+		p += `\b`
+	}
+	return p
+}
+
+// isASCIIWord reports whether \b sees c as part of a word.
+func isASCIIWord(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// numberLines maps every rendered line to the line of the file that starts
+// on it, see sourceLines. Lines of a log are the lines of the file, lines of
+// Markdown are found in the rendered document, counting the front matter and
+// the folded sections.
+func (d *document) numberLines() {
+	d.sourceLines = make([]int, len(d.lines))
+	if d.log {
+		for i, src := range d.logSource {
+			if i == 0 || src != d.logSource[i-1] {
+				d.sourceLines[i] = src + 1
+			}
+		}
+		return
+	}
+
+	var starts []int
+	for i := 0; i < len(d.content); i++ {
+		if i == 0 || d.content[i-1] == '\n' {
+			starts = append(starts, i)
+		}
+	}
+	base := 0
+	if d.deck == nil {
+		base = len(d.content) - len(d.body)
+	}
+	for i, off := range locateSourceLines(parseSourceLines(d.source()), d.lines) {
+		if off < 0 {
+			continue
+		}
+		if d.deck == nil && len(d.folded) > 0 {
+			off = unfoldOffset(off, d.folds, d.folded)
+		}
+		d.sourceLines[i] = sort.SearchInts(starts, base+off+1)
+	}
+}
+
+// sourceLineCount is the number of lines of the file.
+func (d *document) sourceLineCount() int {
+	if d.log {
+		return len(d.logLines)
+	}
+	n := strings.Count(d.content, "\n")
+	if !strings.HasSuffix(d.content, "\n") {
+		n++
+	}
+	return n
+}
+
+// sourceLineAt returns the line of the file the rendered line belongs to,
+// which is the last one starting on it or above it.
+func (d *document) sourceLineAt(row int) int {
+	for i := min(row, len(d.sourceLines)-1); i >= 0; i-- {
+		if d.sourceLines[i] > 0 {
+			return d.sourceLines[i]
+		}
+	}
+	return 1
+}
+
+// gotoSourceLine scrolls to the rendered line the given line of the file
+// starts on, or the next one that is shown if it renders nothing.
+func (d *document) gotoSourceLine(n int) {
+	for i, l := range d.sourceLines {
+		if l >= n {
+			d.viewport.SetYOffset(i)
+			return
+		}
+	}
+	d.viewport.GotoBottom()
+}
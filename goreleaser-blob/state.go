@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxStateDocuments bounds the state file. The documents seen longest ago
+// are forgotten first.
+const maxStateDocuments = 200
+
+// mark is a place in a document: the line at the top of the viewport and the
+// number of lines the document was rendered to at the time. The document
+// reflows with the width of the terminal, so we scale the line when the
+// number of lines changed.
+type mark struct {
+	Line  int `json:"line"`
+	Lines int `json:"lines"`
+}
+
+// line returns the line of the mark in a document of the given length.
+func (k mark) line(lines int) int {
+	if k.Lines <= 0 || k.Lines == lines {
+		return k.Line
+	}
+	return k.Line * lines / k.Lines
+}
+
+// docState is what we remember about a document between runs.
+type docState struct {
+	Hash      string          `json:"hash"`
+	Position  mark            `json:"position"`
+	Bookmarks map[string]mark `json:"bookmarks,omitempty"`
+	Seen      time.Time       `json:"seen"`
+}
+
+// store keeps the reading positions and bookmarks of local documents in a
+// JSON file in the user's state directory, keyed by the absolute path.
+type store struct {
+	path string
+	docs map[string]*docState
+}
+
+// stateDir returns $XDG_STATE_HOME, or its default ~/.local/state.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+// openStore reads the state file. A missing file is an empty store.
+func openStore() (*store, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	s := &store{path: filepath.Join(dir, "goreleaser-blob", "state.json")}
+	s.docs, err = readState(s.path)
+	return s, err
+}
+
+func readState(path string) (map[string]*docState, error) {
+	docs := map[string]*docState{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return docs, nil
+	}
+	if err != nil {
+		return docs, err
+	}
+	if err := json.Unmarshal(b, &docs); err != nil {
+		return map[string]*docState{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return docs, nil
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// attach hands the document what we remember about it. The position is only
+// restored if the document did not change since, the bookmarks always are.
+func (s *store) attach(d *document) {
+	if s == nil || d.path == "" {
+		return
+	}
+	ds, ok := s.docs[d.path]
+	if !ok {
+		return
+	}
+	for name, k := range ds.Bookmarks {
+		d.bookmarks[name] = k
+	}
+	if ds.Hash == contentHash(d.content) {
+		pos := ds.Position
+		d.restore = &pos
+	} else {
+		d.changed = true
+	}
+}
+
+// save writes the positions and bookmarks of the given documents. The file
+// is read again first, so we don't drop what other instances saved.
+func (s *store) save(docs []*document) error {
+	if s == nil {
+		return nil
+	}
+	current, err := readState(s.path)
+	if err != nil {
+		// A broken state file is replaced rather than blocking us forever.
+		current = map[string]*docState{}
+	}
+	now := time.Now()
+	for _, d := range docs {
+		if d.path == "" || len(d.lines) == 0 {
+			continue
+		}
+		current[d.path] = &docState{
+			Hash:      contentHash(d.content),
+			Position:  mark{Line: d.viewport.YOffset, Lines: len(d.lines)},
+			Bookmarks: d.bookmarks,
+			Seen:      now,
+		}
+	}
+	prune(current)
+
+	b, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// prune forgets the documents seen longest ago.
+func prune(docs map[string]*docState) {
+	if len(docs) <= maxStateDocuments {
+		return
+	}
+	paths := make([]string, 0, len(docs))
+	for p := range docs {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return docs[paths[i]].Seen.After(docs[paths[j]].Seen)
+	})
+	for _, p := range paths[maxStateDocuments:] {
+		delete(docs, p)
+	}
+}
+
+// isBookmarkName reports whether the key can name a bookmark, which like in
+// vim is a single letter or digit.
+func isBookmarkName(s string) bool {
+	r := []rune(s)
+	return len(r) == 1 && (unicode.IsLetter(r[0]) || unicode.IsDigit(r[0]))
+}
+
+// setBookmark remembers the current position under the given name.
+func (d *document) setBookmark(name string) {
+	d.bookmarks[name] = mark{Line: d.viewport.YOffset, Lines: len(d.lines)}
+}
+
+// gotoBookmark scrolls back to a bookmark.
+func (d *document) gotoBookmark(name string) error {
+	k, ok := d.bookmarks[name]
+	if !ok {
+		return fmt.Errorf("no bookmark %s", name)
+	}
+	d.viewport.SetYOffset(k.line(len(d.lines)))
+	return nil
+}
+
+// listBookmarks describes the bookmarks of the document by the line of the
+// file they are on, like :N and the gutter, e.g. "a 12, b 310".
+func (d *document) listBookmarks() string {
+	if len(d.bookmarks) == 0 {
+		return "no bookmarks"
+	}
+	names := make([]string, 0, len(d.bookmarks))
+	for name := range d.bookmarks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s %d", name, d.sourceLineAt(d.bookmarks[name].line(len(d.lines))))
+	}
+	return "bookmarks: " + strings.Join(names, ", ")
+}
+
+// deleteBookmarks removes the named bookmarks, or all of them if there are
+// no names.
+func (d *document) deleteBookmarks(names []string) error {
+	if len(names) == 0 {
+		d.bookmarks = map[string]mark{}
+		return nil
+	}
+	for _, name := range names {
+		if _, ok := d.bookmarks[name]; !ok {
+			return fmt.Errorf("no bookmark %s", name)
+		}
+	}
+	for _, name := range names {
+		delete(d.bookmarks, name)
+	}
+	return nil
+}
+
+// savedDocuments returns the documents whose positions are kept between
+// runs. In --diff mode the documents are only shown side by side, never in
+// their own viewports, so there are no positions to keep.
+func (m model) savedDocuments() []*document {
+	if m.diff != nil {
+		return nil
+	}
+	return m.documents()
+}
+
+// documents returns all documents that are open in a tab or in the history
+// of one.
+func (m model) documents() []*document {
+	var docs []*document
+	for _, t := range m.tabs {
+		docs = append(docs, t.documents()...)
+	}
+	return docs
+}
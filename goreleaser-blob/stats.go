@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// The fields the footer can show, see config.Footer.
+const (
+	footerSection = "section"
+	footerWords   = "words"
+	footerTime    = "time"
+	footerLines   = "lines"
+	footerPercent = "percent"
+)
+
+var (
+	footerFields  = []string{footerSection, footerWords, footerTime, footerLines, footerPercent}
+	defaultFooter = footerFields
+)
+
+// wordsPerMinute is the reading speed we estimate reading times with.
+const wordsPerMinute = 200
+
+// maxSectionWidth keeps long headings from pushing everything else out of
+// the footer.
+const maxSectionWidth = 30
+
+func isFooterField(name string) bool {
+	for _, f := range footerFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// countWords returns how many words there are above each of the lines of
+// the text, and in total as the last element. Counting the source rather
+// than what is rendered keeps the count the same whatever the width and
+// folded sections. Bullets, table borders and other markup have no letters or
+// digits and are not counted.
+func countWords(text string) []int {
+	lines := strings.Split(text, "\n")
+	words := make([]int, len(lines)+1)
+	for i, l := range lines {
+		n := 0
+		for _, f := range strings.Fields(ansi.Strip(l)) {
+			if strings.IndexFunc(f, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+				n++
+			}
+		}
+		words[i+1] = words[i] + n
+	}
+	return words
+}
+
+// section returns the heading the top of the screen is in.
+func (d *document) section() string {
+	i := currentHeading(d.headings, d.viewport.YOffset)
+	if i < 0 {
+		return ""
+	}
+	return ansi.Truncate(d.headings[i].text, maxSectionWidth, "…")
+}
+
+// wordCount returns the number of words in the document.
+func (d *document) wordCount() int {
+	if len(d.words) == 0 {
+		return 0
+	}
+	return d.words[len(d.words)-1]
+}
+
+// wordsLeft returns the number of words from the line of the body at the
+// top of the screen on, or from the current slide on in --slides mode.
+func (d *document) wordsLeft() int {
+	if len(d.words) == 0 {
+		return 0
+	}
+	if d.deck != nil {
+		left := 0
+		for _, s := range d.deck.slides[d.deck.current:] {
+			words := countWords(s.body)
+			left += words[len(words)-1]
+		}
+		return left
+	}
+	frontMatter := strings.Count(d.content[:len(d.content)-len(d.body)], "\n")
+	line := d.sourceLineAt(d.viewport.YOffset) - 1 - frontMatter
+	return d.wordCount() - d.words[max(0, min(line, len(d.words)-1))]
+}
+
+// readingTime estimates how long it takes to read the rest of the document,
+// e.g. "4 min left".
+func readingTime(words int) string {
+	return fmt.Sprintf("%d min left", (words+wordsPerMinute-1)/wordsPerMinute)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// stats returns the given footer fields of the document. In --slides mode the
+// position is the slide instead of the lines and percentage.
+func (d *document) stats(fields []string) []string {
+	var out []string
+	for _, f := range fields {
+		switch {
+		case f == footerSection:
+			if s := d.section(); s != "" {
+				out = append(out, s)
+			}
+		case f == footerWords:
+			out = append(out, plural(d.wordCount(), "word"))
+		case f == footerTime:
+			out = append(out, readingTime(d.wordsLeft()))
+		case d.deck != nil && f == footerLines:
+			out = append(out, fmt.Sprintf("slide %d/%d", d.deck.current+1, len(d.deck.slides)))
+		case f == footerLines:
+			out = append(out, d.lineRange())
+		case f == footerPercent && d.deck == nil:
+			out = append(out, fmt.Sprintf("%.f%%", d.viewport.ScrollPercent()*100))
+		}
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	sidebarStyle = lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder(), false, true, false, false).
+			PaddingRight(1)
+
+	tocCurrentStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	tocSelectedStyle = lipgloss.NewStyle().Reverse(true)
+)
+
+// maxSidebarWidth caps the table of contents, so the document keeps most of
+// the screen on wide terminals.
+const maxSidebarWidth = 32
+
+// heading is an entry in the table of contents. Line is the line of the
+// rendered document the heading ends up on, which depends on the width the
+// document was wrapped to. Fold is the index of its section in the document,
+// see parseFolds, or -1 if it can't be folded.
+type heading struct {
+	level int
+	text  string
+	line  int
+	fold  int
+}
+
+// toc is the table of contents sidebar.
+type toc struct {
+	visible  bool
+	selected int
+}
+
+// parseHeadings extracts all headings from the Markdown source in document
+// order.
+func parseHeadings(source string) []heading {
+	src := []byte(source)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	var headings []heading
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering {
+			headings = append(headings, heading{level: h.Level, text: nodeText(h, src)})
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return headings
+}
+
+// nodeText returns the plain text of an inline node and its children.
+func nodeText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(src))
+			if c.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(c.Value)
+		default:
+			b.WriteString(nodeText(c, src))
+		}
+	}
+	return b.String()
+}
+
+// locateHeadings finds the rendered line of every heading. Headings appear
+// in the rendered output in the same order as in the source, so we can scan
+// forward from the previous heading, which keeps duplicate titles apart. A
+// heading that was wrapped is matched by joining its lines again.
+func locateHeadings(headings []heading, lines []string) {
+	plain := make([]string, len(lines))
+	for i, l := range lines {
+		plain[i] = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(ansi.Strip(l)), "#"))
+	}
+
+	from := 0
+	for i := range headings {
+		headings[i].line = -1
+		want := strings.Join(strings.Fields(headings[i].text), " ")
+		for l := from; l < len(plain); l++ {
+			if plain[l] == "" || !strings.HasPrefix(want, plain[l]) {
+				continue
+			}
+			got := plain[l]
+			for k := l + 1; len(got) < len(want) && k < len(plain) && strings.HasPrefix(want, got); k++ {
+				got += " " + plain[k]
+			}
+			if got == want {
+				headings[i].line = l
+				from = l + 1
+				break
+			}
+		}
+	}
+}
+
+// currentHeading returns the index of the heading of the section shown at
+// the top of the viewport, or -1 if we are above the first heading.
+func currentHeading(headings []heading, offset int) int {
+	cur := -1
+	for i, h := range headings {
+		if h.line < 0 {
+			continue
+		}
+		if h.line > offset {
+			break
+		}
+		cur = i
+	}
+	return cur
+}
+
+// sidebarWidth returns the width of the table of contents for a window of the
+// given width.
+func sidebarWidth(width int) int {
+	return min(maxSidebarWidth, width/3)
+}
+
+// view renders the outline of the given headings. Nested headings are
+// indented relative to the top-most heading level used in the document.
+func (t toc) view(headings []heading, current, width, height int) string {
+	style := sidebarStyle.Width(max(0, width-sidebarStyle.GetHorizontalBorderSize())).Height(height)
+	inner := max(0, width-sidebarStyle.GetHorizontalFrameSize())
+	if len(headings) == 0 {
+		return style.Render("No headings")
+	}
+
+	top := headings[0].level
+	for _, h := range headings {
+		top = min(top, h.level)
+	}
+
+	// Keep the selected entry in sight on long outlines.
+	first := max(0, t.selected-height+1)
+
+	var lines []string
+	for i := first; i < len(headings) && len(lines) < height; i++ {
+		h := headings[i]
+		entry := strings.Repeat("  ", h.level-top) + h.text
+		entry = ansi.Truncate(entry, inner, "…")
+		switch {
+		case i == t.selected:
+			entry = tocSelectedStyle.Render(entry)
+		case i == current:
+			entry = tocCurrentStyle.Render(entry)
+		}
+		lines = append(lines, entry)
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+// jumpToHeading scrolls the document so the given heading is at the top of
+// the viewport.
+func (d *document) jumpToHeading(i int) {
+	if i < 0 || i >= len(d.headings) || d.headings[i].line < 0 {
+		return
+	}
+	d.viewport.SetYOffset(d.headings[i].line)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// completeCommand is the hidden command the completion scripts call back
+// into for the values of arguments, which can't be known when the script is
+// generated.
+const completeCommand = "__complete"
+
+// wordFlag goes in front of the word to complete when PowerShell calls
+// completeCommand. PowerShell before 7.3 drops empty arguments, so without it
+// we could not tell an empty word from no word at all.
+const wordFlag = "--word-to-complete"
+
+// shells are the shells we generate completion scripts for.
+var shells = map[string]*template.Template{
+	"bash":       template.Must(template.New("bash").Funcs(templateFuncs).Parse(bashTemplate)),
+	"fish":       template.Must(template.New("fish").Funcs(templateFuncs).Parse(fishTemplate)),
+	"powershell": template.Must(template.New("powershell").Funcs(templateFuncs).Parse(powershellTemplate)),
+	"zsh":        template.Must(template.New("zsh").Funcs(templateFuncs).Parse(zshTemplate)),
+}
+
+func shellNames() []string {
+	names := make([]string, 0, len(shells))
+	for name := range shells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionData is what the completion templates are rendered with: the
+// visible commands and their flags.
+type completionData struct {
+	Name     string
+	Func     string
+	Complete string
+	WordFlag string
+	Commands []completionCommand
+}
+
+type completionCommand struct {
+	Name    string
+	Summary string
+	Flags   []completionFlag
+	// Dynamic is set if the arguments are completed by calling back into
+	// the binary.
+	Dynamic bool
+}
+
+type completionFlag struct {
+	Name  string
+	Usage string
+	Bool  bool
+}
+
+func newCompletionData(cmds []*command) completionData {
+	data := completionData{
+		Name:     name,
+		Func:     "_" + strings.ReplaceAll(name, "-", "_"),
+		Complete: completeCommand,
+		WordFlag: wordFlag,
+	}
+	for _, c := range cmds {
+		if c.hidden {
+			continue
+		}
+		cc := completionCommand{Name: c.name, Summary: c.summary, Dynamic: c.complete != nil}
+		c.flags.VisitAll(func(f *flag.Flag) {
+			cc.Flags = append(cc.Flags, completionFlag{Name: f.Name, Usage: f.Usage, Bool: isBoolFlag(f)})
+		})
+		data.Commands = append(data.Commands, cc)
+	}
+	return data
+}
+
+// Words are the names of the commands, separated by spaces.
+func (d completionData) Words() string {
+	var names []string
+	for _, c := range d.Commands {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// FlagWords are the flags of the command, separated by spaces.
+func (c completionCommand) FlagWords() string {
+	var names []string
+	for _, f := range c.Flags {
+		names = append(names, "--"+f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func newCompletionCommand(out io.Writer, all func() []*command) *command {
+	c := &command{
+		name:    "completion",
+		args:    "<shell>",
+		summary: "Print the completion script for a shell: " + strings.Join(shellNames(), ", ") + ".",
+	}
+	c.flags = newFlagSet(c)
+	c.complete = func(args []string) []string {
+		if len(args) > 0 {
+			return nil
+		}
+		return shellNames()
+	}
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("completion needs a shell, one of %s", strings.Join(shellNames(), ", "))
+		}
+		t, ok := shells[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown shell %q, use one of %s", args[0], strings.Join(shellNames(), ", "))
+		}
+		return t.Execute(out, newCompletionData(all()))
+	}
+	return c
+}
+
+// newCompleteCommand returns the hidden command behind dynamic completions.
+// Its arguments are the words of the command line after the binary name,
+// the last one being the word to complete, which may follow wordFlag, and it
+// prints the candidates one per line.
+func newCompleteCommand(out io.Writer, all func() []*command) *command {
+	c := &command{
+		name:    completeCommand,
+		summary: "Complete the last of the arguments, for the completion scripts.",
+		hidden:  true,
+	}
+	c.run = func(args []string) error {
+		switch n := len(args); {
+		case n > 0 && args[n-1] == wordFlag:
+			args = append(args[:n-1], "")
+		case n > 1 && args[n-2] == wordFlag:
+			args = append(args[:n-2], args[n-1])
+		}
+		for _, candidate := range complete(all(), args) {
+			fmt.Fprintln(out, candidate)
+		}
+		return nil
+	}
+	return c
+}
+
+// complete returns the candidates for the last of the words: the commands
+// for the first word, the flags of the command for words starting with a
+// dash, and whatever the command completes its arguments with otherwise.
+func complete(cmds []*command, words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	prefix := words[len(words)-1]
+	var candidates []string
+	c := findCommand(cmds, words[0])
+	switch {
+	case len(words) == 1:
+		for _, c := range cmds {
+			if !c.hidden {
+				candidates = append(candidates, c.name)
+			}
+		}
+	case c == nil || c.hidden:
+		return nil
+	case strings.HasPrefix(prefix, "-"):
+		c.flags.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "--"+f.Name)
+		})
+	case c.complete != nil:
+		candidates = c.complete(positional(c.flags, words[1:len(words)-1]))
+	}
+
+	var matching []string
+	for _, s := range candidates {
+		if strings.HasPrefix(s, prefix) {
+			matching = append(matching, s)
+		}
+	}
+	return matching
+}
+
+// positional drops the flags and their values from the words.
+func positional(fs *flag.FlagSet, words []string) []string {
+	var args []string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if w == "--" {
+			return append(args, words[i+1:]...)
+		}
+		if !strings.HasPrefix(w, "-") {
+			args = append(args, w)
+			continue
+		}
+		name := strings.TrimLeft(w, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) {
+			i++
+		}
+	}
+	return args
+}
+
+// findCommand returns the command with the given name, or nil.
+func findCommand(cmds []*command, name string) *command {
+	for _, c := range cmds {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+var templateFuncs = template.FuncMap{
+	// quote quotes s for the single quoted strings of POSIX shells.
+	"quote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+	// fishQuote quotes s for fish, which knows backslash escapes in single
+	// quotes.
+	"fishQuote": func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	},
+	// zshSpec escapes s for the descriptions of _arguments and _describe.
+	"zshSpec": func(s string) string {
+		return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+	},
+	// psQuote quotes s for PowerShell.
+	"psQuote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	},
+}
+
+const bashTemplate = `# bash completion for {{.Name}}, load it with
+#   source <({{.Name}} completion bash)
+
+{{.Func}}() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    COMPREPLY=()
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W {{quote .Words}} -- "$cur"))
+        return
+    fi
+    case "${COMP_WORDS[1]}" in
+{{- range .Commands}}
+    {{.Name}})
+{{- range .Flags}}{{if not .Bool}}
+        if [[ $prev == -{{.Name}} || $prev == --{{.Name}} ]]; then
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return
+        fi
+{{- end}}{{end}}
+        if [[ $cur == -* ]]; then
+            COMPREPLY=($(compgen -W {{quote .FlagWords}} -- "$cur"))
+            return
+        fi
+{{- if .Dynamic}}
+        local IFS=$'\n'
+        COMPREPLY=($({{$.Name}} {{$.Complete}} "${COMP_WORDS[@]:1:COMP_CWORD-1}" "$cur" 2>/dev/null))
+{{- end}}
+        ;;
+{{- end}}
+    esac
+}
+
+complete -F {{.Func}} {{.Name}}
+`
+
+const fishTemplate = `# fish completion for {{.Name}}, load it with
+#   {{.Name}} completion fish | source
+
+function {{.Func}}_dynamic
+    set -l args (commandline -opc)
+    set -e args[1]
+    # An empty command substitution is no argument at all, a quoted empty
+    # variable is an empty one.
+    set -l cur (commandline -ct)
+    {{.Name}} {{.Complete}} $args "$cur" 2>/dev/null
+end
+
+complete -c {{.Name}} -f
+{{- range .Commands}}
+complete -c {{$.Name}} -n __fish_use_subcommand -a {{.Name}} -d {{fishQuote .Summary}}
+{{- $cmd := .Name}}
+{{- range .Flags}}
+complete -c {{$.Name}} -n '__fish_seen_subcommand_from {{$cmd}}' -l {{.Name}}{{if not .Bool}} -r{{end}} -d {{fishQuote .Usage}}
+{{- end}}
+{{- if .Dynamic}}
+complete -c {{$.Name}} -n '__fish_seen_subcommand_from {{$cmd}}' -a '({{$.Func}}_dynamic)'
+{{- end}}
+{{- end}}
+`
+
+const zshTemplate = `#compdef {{.Name}}
+# zsh completion for {{.Name}}, put it in your $fpath as _{{.Name}} or load it with
+#   source <({{.Name}} completion zsh)
+
+{{.Func}}_dynamic() {
+    local -a values
+    values=(${(f)"$({{.Name}} {{.Complete}} ${words[1,CURRENT-1]} "${words[CURRENT]}" 2>/dev/null)"})
+    compadd -a values
+}
+
+{{.Func}}() {
+    local -a commands
+    commands=(
+{{- range .Commands}}
+        '{{.Name}}:{{zshSpec .Summary}}'
+{{- end}}
+    )
+    if (( CURRENT == 2 )); then
+        _describe 'command' commands
+        return
+    fi
+    shift words
+    (( CURRENT-- ))
+    case $words[1] in
+{{- range .Commands}}
+    {{.Name}})
+        _arguments{{range .Flags}} \
+            '--{{.Name}}{{if not .Bool}}={{end}}[{{zshSpec .Usage}}]{{if not .Bool}}:{{.Name}}:_default{{end}}'{{end}}{{if .Dynamic}} \
+            '*:argument:{{$.Func}}_dynamic'{{end}}
+        ;;
+{{- end}}
+    esac
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    {{.Func}} "$@"
+else
+    compdef {{.Func}} {{.Name}}
+fi
+`
+
+const powershellTemplate = `# PowerShell completion for {{.Name}}, load it with
+#   {{.Name}} completion powershell | Out-String | Invoke-Expression
+
+Register-ArgumentCompleter -Native -CommandName {{psQuote .Name}} -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.EndOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() })
+    $candidates = @()
+    if ($words.Count -le 1) {
+        $candidates = @(
+{{- range .Commands}}
+            ,@({{psQuote .Name}}, {{psQuote .Summary}})
+{{- end}}
+        )
+    } elseif ($wordToComplete -like '-*') {
+        switch ($words[1]) {
+{{- range .Commands}}
+            {{psQuote .Name}} {
+                $candidates = @(
+{{- range .Flags}}
+                    ,@({{psQuote (print "--" .Name)}}, {{psQuote .Usage}})
+{{- end}}
+                )
+            }
+{{- end}}
+        }
+    } else {
+        switch ($words[1]) {
+{{- range .Commands}}{{if .Dynamic}}
+            {{psQuote .Name}} {
+                $candidates = @(& {{psQuote $.Name}} {{$.Complete}} @($words | Select-Object -Skip 1) {{$.WordFlag}} "$wordToComplete" 2>$null |
+                    ForEach-Object { ,@($_, $_) })
+            }
+{{- end}}{{end}}
+        }
+    }
+    $candidates | Where-Object { $_[0] -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_[0], $_[0], 'ParameterValue', $_[1])
+    }
+}
+`
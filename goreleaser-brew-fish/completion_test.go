@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"commands", []string{""}, []string{"version", "update", "completion"}},
+		{"partial command", []string{"up"}, []string{"update"}},
+		{"empty argument", []string{"update", ""}, []string{"check"}},
+		{"partial argument", []string{"update", "ch"}, []string{"check"}},
+		{"no match", []string{"update", "x"}, nil},
+		{"after flags", []string{"update", "--endpoint", "http://localhost", ""}, []string{"check"}},
+		{"argument given", []string{"update", "check", ""}, nil},
+		{"flags", []string{"update", "--"}, []string{"--endpoint", "--refresh"}},
+		{"partial flag", []string{"version", "--j"}, []string{"--json"}},
+		{"shells", []string{"completion", ""}, []string{"bash", "fish", "powershell", "zsh"}},
+		{"partial shell", []string{"completion", "p"}, []string{"powershell"}},
+		{"hidden command", []string{completeCommand, ""}, nil},
+		{"unknown command", []string{"nope", ""}, nil},
+		{"empty word after wordFlag", []string{"update", wordFlag}, []string{"check"}},
+		{"partial word after wordFlag", []string{"update", wordFlag, "ch"}, []string{"check"}},
+		{"flag after wordFlag", []string{"update", wordFlag, "--r"}, []string{"--refresh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(append([]string{completeCommand}, tt.args...), &out); err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Fields(out.String())
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	tests := []struct {
+		shell string
+		// want is how the script passes the word to complete, which has
+		// to survive being empty.
+		want string
+	}{
+		{"bash", `"$cur"`},
+		{"fish", `"$cur"`},
+		{"zsh", `"${words[CURRENT]}"`},
+		{"powershell", wordFlag + ` "$wordToComplete"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run([]string{"completion", tt.shell}, &out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), completeCommand) || !strings.Contains(out.String(), tt.want) {
+				t.Errorf("the %s script does not call %s with %s:\n%s", tt.shell, completeCommand, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestCompletionUnknownShell(t *testing.T) {
+	err := run([]string{"completion", "tcsh"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), `unknown shell "tcsh"`) {
+		t.Errorf("got error %v", err)
+	}
+}
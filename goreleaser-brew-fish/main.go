@@ -1,9 +1,16 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 )
 
+// Set by goreleaser with ldflags, see buildInfo for binaries built without
+// them.
 var (
 	version = "0.0.1"
 	commit  = "none"
@@ -11,9 +18,97 @@ var (
 	builtBy = "none"
 )
 
+const name = "goreleaser-brew-fish"
+
+// command is a subcommand of the CLI. Its flags are parsed before run is
+// called with the arguments that are left. Commands without flags get their
+// arguments as they are.
+type command struct {
+	name    string
+	args    string
+	summary string
+	flags   *flag.FlagSet
+	run     func(args []string) error
+
+	// complete returns the values the next argument can take, given the
+	// arguments before it. Hidden commands are left out of the completions.
+	complete func(args []string) []string
+	hidden   bool
+}
+
+// commands returns all commands, writing their output to out.
+func commands(out io.Writer) []*command {
+	var cmds []*command
+	all := func() []*command { return cmds }
+	cmds = []*command{
+		newVersionCommand(out),
+		newUpdateCommand(out),
+		newCompletionCommand(out, all),
+		newCompleteCommand(out, all),
+	}
+	return cmds
+}
+
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		usage := strings.TrimSpace(fmt.Sprintf("%s %s [flags] %s", name, c.name, c.args))
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\n%s\n\nFlags:\n", usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
 func main() {
-	fmt.Println("Version:\t", version)
-	fmt.Println("Commit:\t\t", commit)
-	fmt.Println("Date:\t\t", date)
-	fmt.Println("Built by:\t", builtBy)
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command named by the first argument. Without one we print the
+// version, like the binary always did.
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"version"}
+	}
+	cmds := commands(out)
+	var names []string
+	for _, c := range cmds {
+		if c.name != args[0] {
+			if !c.hidden {
+				names = append(names, c.name)
+			}
+			continue
+		}
+		if c.flags == nil {
+			return c.run(args[1:])
+		}
+		rest, err := parseArgs(c.flags, args[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return c.run(rest)
+	}
+	return fmt.Errorf("unknown command %q, use one of %s", args[0], strings.Join(names, ", "))
+}
+
+// parseArgs parses the flags wherever they are among the arguments, not
+// only in front of them like the flag package does, so that
+// "update check --refresh" works. It returns the arguments that are left.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
 }
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReleasesURL is where we look for new releases, unless the endpoint
+// is set with --endpoint or releasesEnv, e.g. to point it at a mirror.
+const (
+	defaultReleasesURL = "https://api.github.com/repos/dirien/quick-bites/releases"
+	releasesEnv        = "GORELEASER_BREW_FISH_RELEASES_URL"
+)
+
+// cacheTTL is how long we trust the last answer of the endpoint.
+const cacheTTL = 24 * time.Hour
+
+// release is the part of a GitHub release we need.
+type release struct {
+	TagName    string `json:"tag_name"`
+	HTMLURL    string `json:"html_url"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// cachedRelease is the latest release, as the endpoint told us at the time.
+type cachedRelease struct {
+	Endpoint  string    `json:"endpoint"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Release   release   `json:"release"`
+}
+
+func newUpdateCommand(out io.Writer) *command {
+	c := &command{
+		name:    "update",
+		args:    "check",
+		summary: "Check whether there is a newer release, and how to upgrade to it.",
+	}
+	c.flags = newFlagSet(c)
+	endpoint := c.flags.String("endpoint", releasesURL(), "the releases endpoint, in the format of the GitHub releases API (env "+releasesEnv+")")
+	refresh := c.flags.Bool("refresh", false, "ask the endpoint even if the last answer is less than a day old")
+	c.complete = func(args []string) []string {
+		if len(args) > 0 {
+			return nil
+		}
+		return []string{"check"}
+	}
+	c.run = func(args []string) error {
+		if len(args) != 1 || args[0] != "check" {
+			return errors.New("update needs a subcommand: check")
+		}
+		current := buildInfo().Version
+		cv, ok := parseSemver(current)
+		if !ok {
+			return fmt.Errorf("version %q is not a semantic version, there is nothing to compare it with", current)
+		}
+		latest, err := latestRelease(*endpoint, *refresh, time.Now())
+		if err != nil {
+			return err
+		}
+		lv, _ := parseSemver(latest.TagName)
+		if lv.compare(cv) <= 0 {
+			fmt.Fprintf(out, "%s %s is up to date.\n", name, current)
+			return nil
+		}
+		fmt.Fprintf(out, "%s %s is out, you have %s: %s\n", name, latest.TagName, current, latest.HTMLURL)
+		fmt.Fprintf(out, "Upgrade with: %s\n", upgradePath(latest))
+		return nil
+	}
+	return c
+}
+
+func releasesURL() string {
+	if u := os.Getenv(releasesEnv); u != "" {
+		return u
+	}
+	return defaultReleasesURL
+}
+
+// latestRelease returns the latest release the endpoint knows of, from the
+// cache if we asked less than a day ago.
+func latestRelease(endpoint string, refresh bool, now time.Time) (release, error) {
+	path, cacheErr := cachePath()
+	if cacheErr == nil && !refresh {
+		if c, err := readCache(path); err == nil && c.Endpoint == endpoint && now.Sub(c.CheckedAt) < cacheTTL {
+			return c.Release, nil
+		}
+	}
+	r, err := fetchLatestRelease(endpoint)
+	if err != nil {
+		return release{}, err
+	}
+	if cacheErr == nil {
+		// Not being able to cache only means we ask again next time.
+		_ = writeCache(path, cachedRelease{Endpoint: endpoint, CheckedAt: now, Release: r})
+	}
+	return r, nil
+}
+
+// cachePath is where we keep the last answer of the endpoint, in the user
+// cache dir, e.g. ~/.cache on Linux.
+func cachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name, "latest-release.json"), nil
+}
+
+func readCache(path string) (cachedRelease, error) {
+	var c cachedRelease
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(b, &c)
+}
+
+func writeCache(path string, c cachedRelease) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// fetchLatestRelease asks the endpoint for its releases. It may answer with
+// a single release, like GitHub's /releases/latest, or a list of them, like
+// /releases, from which we pick the highest version that is neither a draft
+// nor a prerelease.
+func fetchLatestRelease(endpoint string) (release, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", name+"/"+version)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return release{}, fmt.Errorf("could not check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("could not check for updates: %s answered %s", endpoint, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return release{}, fmt.Errorf("could not check for updates: %w", err)
+	}
+
+	var releases []release
+	if err := json.Unmarshal(body, &releases); err != nil {
+		var r release
+		if err := json.Unmarshal(body, &r); err != nil {
+			return release{}, fmt.Errorf("could not check for updates: %s is not a release or a list of releases: %w", endpoint, err)
+		}
+		releases = []release{r}
+	}
+
+	var (
+		latest release
+		lv     semver
+		found  bool
+	)
+	for _, r := range releases {
+		v, ok := parseSemver(r.TagName)
+		if !ok || r.Draft || r.Prerelease {
+			continue
+		}
+		if !found || v.compare(lv) > 0 {
+			latest, lv, found = r, v, true
+		}
+	}
+	if !found {
+		return release{}, fmt.Errorf("could not check for updates: %s has no releases with a semantic version", endpoint)
+	}
+	return latest, nil
+}
+
+// upgradePath tells how to upgrade, depending on how the binary was
+// installed, which we guess from where it lives.
+func upgradePath(r release) string {
+	exe, err := os.Executable()
+	if err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		exe = filepath.ToSlash(exe)
+		switch {
+		case strings.Contains(exe, "/Cellar/"), strings.Contains(exe, "/homebrew/"), strings.Contains(exe, "/linuxbrew/"):
+			return "brew upgrade " + name
+		case strings.Contains(exe, "/Fish/Barrel/"):
+			return "gofish upgrade " + name
+		}
+	}
+	return "download it from " + r.HTMLURL
+}
+
+// semver is a semantic version, see https://semver.org. Build metadata is
+// dropped, since it doesn't count when comparing versions.
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseSemver parses a version like v1.2.3 or 1.2.3-rc.1, with or without
+// the v in front.
+func parseSemver(s string) (semver, bool) {
+	var v semver
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+		for _, p := range v.pre {
+			if p == "" {
+				return semver{}, false
+			}
+		}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || p[0] == '+' {
+			return semver{}, false
+		}
+		*nums[i] = n
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 if v is lower than, equal to or higher than o.
+// A prerelease is lower than the release itself.
+func (v semver) compare(o semver) int {
+	for _, d := range [][2]int{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if c := compareInts(d[0], d[1]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		a, aErr := strconv.Atoi(v.pre[i])
+		b, bErr := strconv.Atoi(o.pre[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInts(a, b); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			// Numeric identifiers are lower than alphanumeric ones.
+			return -1
+		case bErr == nil:
+			return 1
+		case v.pre[i] != o.pre[i]:
+			if v.pre[i] < o.pre[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareInts(len(v.pre), len(o.pre))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setVersion pretends the binary was built by goreleaser as the given
+// version for the duration of the test.
+func setVersion(t *testing.T, v string) {
+	t.Helper()
+	oldVersion, oldCommit := version, commit
+	version, commit = v, "abc123"
+	t.Cleanup(func() { version, commit = oldVersion, oldCommit })
+}
+
+// useTempCache keeps the tests away from the real cache.
+func useTempCache(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+}
+
+// serveReleases answers every request with the given status and body, and
+// counts the requests.
+func serveReleases(t *testing.T, status int, body string) (*httptest.Server, *int) {
+	t.Helper()
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+const releasesBody = `[
+	{"tag_name": "v1.3.0-rc.1", "html_url": "https://example.com/v1.3.0-rc.1", "prerelease": true},
+	{"tag_name": "v1.2.0", "html_url": "https://example.com/v1.2.0"},
+	{"tag_name": "nightly", "html_url": "https://example.com/nightly"},
+	{"tag_name": "v1.1.0", "html_url": "https://example.com/v1.1.0"}
+]`
+
+func TestUpdateCheck(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"1.1.0", "goreleaser-brew-fish v1.2.0 is out, you have 1.1.0: https://example.com/v1.2.0\n"},
+		{"1.2.0", "goreleaser-brew-fish 1.2.0 is up to date.\n"},
+		{"v1.2.0", "goreleaser-brew-fish v1.2.0 is up to date.\n"},
+		{"1.2.1", "goreleaser-brew-fish 1.2.1 is up to date.\n"},
+		{"1.2.0-rc.2", "goreleaser-brew-fish v1.2.0 is out, you have 1.2.0-rc.2: https://example.com/v1.2.0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			useTempCache(t)
+			setVersion(t, tt.version)
+			srv, _ := serveReleases(t, http.StatusOK, releasesBody)
+
+			var out bytes.Buffer
+			if err := run([]string{"update", "check", "--endpoint", srv.URL}, &out); err != nil {
+				t.Fatal(err)
+			}
+			first, _, _ := strings.Cut(out.String(), "Upgrade with:")
+			if first != tt.want {
+				t.Errorf("got %q, want %q", first, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchLatestReleaseSingle(t *testing.T) {
+	srv, _ := serveReleases(t, http.StatusOK, `{"tag_name": "v2.0.0", "html_url": "https://example.com/v2.0.0"}`)
+	r, err := fetchLatestRelease(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TagName != "v2.0.0" {
+		t.Errorf("got %q, want v2.0.0", r.TagName)
+	}
+}
+
+func TestFetchLatestReleaseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"malformed", http.StatusOK, `<html>`, "is not a release or a list of releases"},
+		{"status", http.StatusInternalServerError, `[]`, "answered 500 Internal Server Error"},
+		{"no versions", http.StatusOK, `[{"tag_name": "nightly"}]`, "has no releases with a semantic version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := serveReleases(t, tt.status, tt.body)
+			_, err := fetchLatestRelease(srv.URL)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestReleaseCache(t *testing.T) {
+	useTempCache(t)
+	srv, hits := serveReleases(t, http.StatusOK, releasesBody)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, tt := range []struct {
+		now     time.Time
+		refresh bool
+		hits    int
+	}{
+		{now, false, 1},
+		{now.Add(time.Hour), false, 1},
+		{now.Add(time.Hour), true, 2},
+		{now.Add(time.Hour + cacheTTL), false, 3},
+	} {
+		r, err := latestRelease(srv.URL, tt.refresh, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.TagName != "v1.2.0" {
+			t.Errorf("%d: got %q, want v1.2.0", i, r.TagName)
+		}
+		if *hits != tt.hits {
+			t.Errorf("%d: the endpoint was asked %d times, want %d", i, *hits, tt.hits)
+		}
+	}
+
+	// The cache is only good for the endpoint it came from.
+	other, otherHits := serveReleases(t, http.StatusOK, `[{"tag_name": "v9.0.0"}]`)
+	r, err := latestRelease(other.URL, false, now.Add(time.Hour+cacheTTL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TagName != "v9.0.0" || *otherHits != 1 {
+		t.Errorf("got %q after %d requests, want v9.0.0 after 1", r.TagName, *otherHits)
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"1.2.3", true},
+		{"v1.2.3", true},
+		{"1.2.3-rc.1", true},
+		{"1.2.3+build.5", true},
+		{"1.2", false},
+		{"1.2.3-", false},
+		{"1.2.x", false},
+		{"vv1.2.3", false},
+		{"nightly", false},
+	}
+	for _, tt := range tests {
+		if _, ok := parseSemver(tt.in); ok != tt.ok {
+			t.Errorf("parseSemver(%q) ok = %v, want %v", tt.in, ok, tt.ok)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	// In increasing order, see https://semver.org/#spec-item-11.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"v1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"v1.10.0",
+		"2.0.0",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			va, _ := parseSemver(a)
+			vb, _ := parseSemver(b)
+			if got, want := va.compare(vb), compareInts(i, j); got != want {
+				t.Errorf("compare(%s, %s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+
+	va, _ := parseSemver("v1.2.3+build.1")
+	vb, _ := parseSemver("1.2.3")
+	if va.compare(vb) != 0 {
+		t.Error("build metadata should not count")
+	}
+}
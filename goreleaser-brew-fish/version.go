@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// info is where the binary came from.
+type info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"builtBy"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// buildInfo returns the version goreleaser built the binary with. Binaries
+// built without its ldflags, like with go install, fall back to what the Go
+// toolchain recorded: the module version and the commit it was built from.
+func buildInfo() info {
+	i := info{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		BuiltBy:   builtBy,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if commit != "none" {
+		return i
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return i
+	}
+	if v := bi.Main.Version; v != "" && v != "(devel)" {
+		i.Version = v
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			i.Commit = s.Value
+		case "vcs.time":
+			i.Date = s.Value
+		case "vcs.modified":
+			i.Modified = s.Value == "true"
+		}
+	}
+	return i
+}
+
+func newVersionCommand(out io.Writer) *command {
+	c := &command{
+		name:    "version",
+		summary: "Print the version, the commit and how the binary was built.",
+	}
+	c.flags = newFlagSet(c)
+	asJSON := c.flags.Bool("json", false, "print the version as JSON")
+	short := c.flags.Bool("short", false, "print the version number only")
+	c.run = func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("version takes no arguments")
+		}
+		if *asJSON && *short {
+			return errors.New("--json and --short can't be used together")
+		}
+		i := buildInfo()
+		switch {
+		case *short:
+			fmt.Fprintln(out, i.Version)
+		case *asJSON:
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(i)
+		default:
+			commit := i.Commit
+			if i.Modified {
+				commit += " (modified)"
+			}
+			fmt.Fprintln(out, "Version:\t", i.Version)
+			fmt.Fprintln(out, "Commit:\t\t", commit)
+			fmt.Fprintln(out, "Date:\t\t", i.Date)
+			fmt.Fprintln(out, "Built by:\t", i.BuiltBy)
+			fmt.Fprintln(out, "Go version:\t", i.GoVersion)
+			fmt.Fprintln(out, "OS/Arch:\t", i.OS+"/"+i.Arch)
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// setBuild pretends goreleaser also stamped the date and the builder of the
+// binary, on top of setVersion.
+func setBuild(t *testing.T) {
+	t.Helper()
+	setVersion(t, "1.2.0")
+	oldDate, oldBuiltBy := date, builtBy
+	date, builtBy = "2024-05-01T12:00:00Z", "goreleaser"
+	t.Cleanup(func() { date, builtBy = oldDate, oldBuiltBy })
+}
+
+func TestVersion(t *testing.T) {
+	setBuild(t)
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	want := "Version:\t 1.2.0\n" +
+		"Commit:\t\t abc123\n" +
+		"Date:\t\t 2024-05-01T12:00:00Z\n" +
+		"Built by:\t goreleaser\n" +
+		"Go version:\t " + runtime.Version() + "\n" +
+		"OS/Arch:\t " + platform + "\n"
+
+	for _, args := range [][]string{nil, {"version"}} {
+		var out bytes.Buffer
+		if err := run(args, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want {
+			t.Errorf("%q: got %q, want %q", args, out.String(), want)
+		}
+	}
+}
+
+func TestVersionShort(t *testing.T) {
+	setBuild(t)
+	var out bytes.Buffer
+	if err := run([]string{"version", "--short"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "1.2.0\n" {
+		t.Errorf("got %q, want %q", out.String(), "1.2.0\n")
+	}
+}
+
+func TestVersionJSON(t *testing.T) {
+	setBuild(t)
+	var out bytes.Buffer
+	if err := run([]string{"version", "--json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var got info
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v", out.String(), err)
+	}
+	want := info{
+		Version:   "1.2.0",
+		Commit:    "abc123",
+		Date:      "2024-05-01T12:00:00Z",
+		BuiltBy:   "goreleaser",
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !strings.Contains(out.String(), "\n  \"builtBy\"") {
+		t.Errorf("the JSON is not indented:\n%s", out.String())
+	}
+}
+
+func TestVersionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"arguments", []string{"version", "extra"}, "version takes no arguments"},
+		{"both formats", []string{"version", "--json", "--short"}, "--json and --short can't be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(tt.args, &out)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+			if out.Len() > 0 {
+				t.Errorf("printed %q on error", out.String())
+			}
+		})
+	}
+}
+
+func TestBuildInfoFallback(t *testing.T) {
+	// Without goreleaser's ldflags the Go toolchain is asked instead, which
+	// knows nothing about test binaries, so the defaults stay.
+	oldVersion, oldCommit := version, commit
+	version, commit = "0.0.1", "none"
+	t.Cleanup(func() { version, commit = oldVersion, oldCommit })
+
+	i := buildInfo()
+	if i.Version != "0.0.1" || i.Commit != "none" || i.Modified {
+		t.Errorf("got version %q, commit %q, modified %v, want 0.0.1, none, false", i.Version, i.Commit, i.Modified)
+	}
+	if i.GoVersion != runtime.Version() {
+		t.Errorf("got Go version %q, want %q", i.GoVersion, runtime.Version())
+	}
+}